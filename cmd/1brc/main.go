@@ -0,0 +1,10 @@
+// Command 1brc aggregates One Billion Row Challenge measurements, lines of
+// station;temperature, into the min, mean and max of every station. Run it
+// with -h for its flags; the work is done by package onebrc.
+package main
+
+import "github.com/nbukhari/1brc/onebrc"
+
+func main() {
+	onebrc.Main()
+}
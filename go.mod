@@ -2,7 +2,10 @@ module github.com/nbukhari/1brc
 
 go 1.22.1
 
-require github.com/pkg/profile v1.7.0
+require (
+	github.com/pkg/profile v1.7.0
+	golang.org/x/sys v0.30.0
+)
 
 require (
 	github.com/felixge/fgprof v0.9.3 // indirect
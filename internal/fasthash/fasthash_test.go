@@ -0,0 +1,363 @@
+package fasthash
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func keys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("station-%05d", i))
+	}
+	return keys
+}
+
+func TestSetAndGet(t *testing.T) {
+	m := NewHashMap[string, int](16, 16*BucketsPerKey)
+	names := keys(10)
+	for i, key := range names {
+		m.SetUsingHashAndKey(HashBytes64(key), key, i)
+	}
+	if m.Len() != len(names) {
+		t.Fatalf("Len() = %d, want %d", m.Len(), len(names))
+	}
+	for i, key := range names {
+		// a copy, so the bytes are compared, not the pointer
+		if v, ok := m.GetUsingHashAndKey(HashBytes64(key), append([]byte(nil), key...)); !ok || v != i {
+			t.Errorf("GetUsingHashAndKey(%q) = %d, %v, want %d, true", key, v, ok, i)
+		}
+	}
+	missing := []byte("nowhere")
+	if v, ok := m.GetUsingHashAndKey(HashBytes64(missing), missing); ok {
+		t.Errorf("GetUsingHashAndKey(%q) = %d, true for a key never set", missing, v)
+	}
+}
+
+func TestCollidingHashes(t *testing.T) {
+	m := NewHashMap[string, int](4, 4*BucketsPerKey)
+	names := keys(8)
+	for i, key := range names {
+		m.SetUsingHashAndKey(42, key, i)
+	}
+	for i, key := range names {
+		if v, ok := m.GetUsingHashAndKey(42, key); !ok || v != i {
+			t.Errorf("GetUsingHashAndKey(42, %q) = %d, %v, want %d, true", key, v, ok, i)
+		}
+		if v, ok := m.GetUsingHashFunc(42, func(v int) bool { return v == i }); !ok || v != i {
+			t.Errorf("GetUsingHashFunc(42, == %d) = %d, %v", i, v, ok)
+		}
+	}
+	// same hash and length, other bytes
+	if v, ok := m.GetUsingHashAndKey(42, []byte("station-99999")); ok {
+		t.Errorf("GetUsingHashAndKey found %d for a colliding key never set", v)
+	}
+	if _, longest, _ := m.ChainStats(); longest != len(names) {
+		t.Errorf("longest probe %d, want %d for keys sharing one hash", longest, len(names))
+	}
+}
+
+// Keys of up to 16 bytes are compared as words, longer ones also by the
+// bytes past them, so keys sharing a hash must stay apart around that
+// boundary, including ones whose extra bytes are zeros.
+func TestCollidingKeyLengths(t *testing.T) {
+	m := NewHashMap[string, int](4, 4*BucketsPerKey)
+	var names [][]byte
+	for n := range 24 {
+		x := bytes.Repeat([]byte("x"), n)
+		names = append(names, x, append(x[:n:n], 0), append(x[:n:n], 'y'))
+	}
+	for i, key := range names {
+		m.SetUsingHashAndKey(42, key, i)
+	}
+	for i, key := range names {
+		if v, ok := m.GetUsingHashAndKey(42, append([]byte(nil), key...)); !ok || v != i {
+			t.Errorf("GetUsingHashAndKey(42, %q) = %d, %v, want %d, true", key, v, ok, i)
+		}
+	}
+	for _, key := range [][]byte{[]byte("xxxxxxxxxxxxxxxxxxxxxxxxz"), bytes.Repeat([]byte("x"), 30)} {
+		if v, ok := m.GetUsingHashAndKey(42, key); ok {
+			t.Errorf("GetUsingHashAndKey(42, %q) found %d for a key never set", key, v)
+		}
+	}
+}
+
+func TestGrow(t *testing.T) {
+	m := NewHashMap[string, int](1, 1)
+	names := keys(5000)
+	for i, key := range names {
+		m.SetUsingHashAndKey(HashBytes64(key), key, i)
+	}
+	if m.Len() != len(names) {
+		t.Fatalf("Len() = %d, want %d", m.Len(), len(names))
+	}
+	if m.Slots() < 2*m.Len() {
+		t.Errorf("%d slots for %d keys, the table must stay under half full", m.Slots(), m.Len())
+	}
+	for i, key := range names {
+		if v, ok := m.GetUsingHashAndKey(HashBytes64(key), key); !ok || v != i {
+			t.Fatalf("after growing, GetUsingHashAndKey(%q) = %d, %v, want %d, true", key, v, ok, i)
+		}
+	}
+	seen := 0
+	m.Range(func(hash uint64, v int) bool {
+		if hash != HashBytes64(names[v]) {
+			t.Errorf("Range: value %d under hash %x, want %x", v, hash, HashBytes64(names[v]))
+		}
+		seen++
+		return true
+	})
+	if seen != len(names) {
+		t.Errorf("Range visited %d values, want %d", seen, len(names))
+	}
+}
+
+func TestReset(t *testing.T) {
+	m := NewHashMap[string, int](8, 8*BucketsPerKey)
+	names := keys(100)
+	for i, key := range names {
+		m.SetUsingHashAndKey(HashBytes64(key), key, i)
+	}
+	slots := m.Slots()
+	m.Reset()
+	if m.Len() != 0 || m.Slots() != slots {
+		t.Fatalf("after Reset: Len() = %d, Slots() = %d, want 0, %d", m.Len(), m.Slots(), slots)
+	}
+	for _, key := range names {
+		if _, ok := m.GetUsingHashAndKey(HashBytes64(key), key); ok {
+			t.Fatalf("GetUsingHashAndKey(%q) found a value after Reset", key)
+		}
+	}
+	m.SetUsingHashAndKey(HashBytes64(names[7]), names[7], 70)
+	if v, ok := m.GetUsingHashAndKey(HashBytes64(names[7]), names[7]); !ok || v != 70 {
+		t.Errorf("after Reset and Set, got %d, %v, want 70, true", v, ok)
+	}
+}
+
+// The hashes are FNV-1a, byte for byte: every length through the
+// unrolled loops, and multibyte UTF-8.
+func TestFNV1a(t *testing.T) {
+	inputs := []string{"Hamburg", "Petropavlovsk-Kamchatsky", "Las Palmas de Gran Canaria",
+		"Ho Chi Minh City", "São Tomé", "Düsseldorf-Oberkassel", "東京都千代田区丸の内一丁目"}
+	for n := range 41 {
+		inputs = append(inputs, strings.Repeat("abcdefghij", 5)[:n])
+	}
+	for _, in := range inputs {
+		h := fnv.New64a()
+		h.Write([]byte(in))
+		want := h.Sum64()
+		if got := HashBytes64([]byte(in)); got != want {
+			t.Errorf("HashBytes64(%q) = %#016x, want %#016x", in, got, want)
+		}
+		if got := HashString64(in); got != want {
+			t.Errorf("HashString64(%q) = %#016x, want %#016x", in, got, want)
+		}
+	}
+}
+
+// BenchmarkLoadFactor looks up every key of a table of 16384 slots holding
+// from 1/16 to 1/2 as many keys, the most it holds before doubling. See
+// BucketsPerKey.
+func BenchmarkLoadFactor(b *testing.B) {
+	const slots = 1 << 14
+	for _, n := range []int{slots / 16, slots / 8, slots / 4, slots / 2} {
+		b.Run(fmt.Sprintf("load=%.4f", float64(n)/slots), func(b *testing.B) {
+			m := NewHashMap[string, int](uint64(n), slots)
+			names := keys(n)
+			hashes := make([]uint64, n)
+			words := make([][2]uint64, n)
+			for i, key := range names {
+				hashes[i] = HashBytes64(key)
+				m.SetUsingHashAndKey(hashes[i], key, i)
+				words[i][0], words[i][1] = KeyWords(key)
+			}
+			if m.Slots() != slots {
+				b.Fatalf("%d slots, want %d", m.Slots(), slots)
+			}
+			b.ResetTimer()
+			for i := range b.N {
+				k := i % n
+				if _, ok := m.GetUsingHashAndWords(hashes[k], words[k][0], words[k][1], names[k]); !ok {
+					b.Fatal("key not found")
+				}
+			}
+		})
+	}
+}
+
+// chainedMap is the bucket-chained table Map replaced, kept as the baseline
+// of BenchmarkOpenAddressing: a slice of entries per bucket, doubled once
+// the buckets hold one entry each on average.
+type chainedMap struct {
+	n       int
+	buckets [][]entry
+	cache   []int
+}
+
+func newChainedMap(nBuckets uint64) *chainedMap {
+	buckets := make([][]entry, nextPowerOfTwo(nBuckets))
+	// the old table preallocated 5 entries per bucket
+	for i := range buckets {
+		buckets[i] = make([]entry, 0, 5)
+	}
+	return &chainedMap{buckets: buckets, cache: []int{0}}
+}
+
+func (m *chainedMap) set(hash uint64, key []byte, v int) {
+	m.n++
+	m.cache = append(m.cache, v)
+	if m.n > len(m.buckets) {
+		old := m.buckets
+		m.buckets = make([][]entry, 2*len(old))
+		for _, bucket := range old {
+			for _, e := range bucket {
+				m.insert(e)
+			}
+		}
+	}
+	m.insert(entry{key: hash, mid: int32(m.n), nameLength: int32(len(key)), name: unsafe.Pointer(unsafe.SliceData(key))})
+}
+
+func (m *chainedMap) insert(e entry) {
+	i := HashToIndex(e.key, uint64(len(m.buckets)))
+	m.buckets[i] = append(m.buckets[i], e)
+}
+
+func (m *chainedMap) get(hash uint64, key []byte) (int, bool) {
+	for _, e := range m.buckets[HashToIndex(hash, uint64(len(m.buckets)))] {
+		if e.key == hash && int(e.nameLength) == len(key) && bytes.Equal(unsafe.Slice((*byte)(e.name), e.nameLength), key) {
+			return m.cache[e.mid], true
+		}
+	}
+	return 0, false
+}
+
+// BenchmarkOpenAddressing compares Map with chainedMap at the 10,000
+// stations of the 1brc spec, inserting them into a fresh table and looking
+// them up.
+func BenchmarkOpenAddressing(b *testing.B) {
+	const n = 10_000
+	names := keys(n)
+	hashes := make([]uint64, n)
+	for i, key := range names {
+		hashes[i] = HashBytes64(key)
+	}
+
+	b.Run("insert/open", func(b *testing.B) {
+		for range b.N {
+			m := NewHashMap[string, int](n, n*BucketsPerKey)
+			for i, key := range names {
+				m.SetUsingHashAndKey(hashes[i], key, i)
+			}
+		}
+	})
+	b.Run("insert/chained", func(b *testing.B) {
+		for range b.N {
+			m := newChainedMap(n * BucketsPerKey)
+			for i, key := range names {
+				m.set(hashes[i], key, i)
+			}
+		}
+	})
+
+	open := NewHashMap[string, int](n, n*BucketsPerKey)
+	chained := newChainedMap(n * BucketsPerKey)
+	words := make([][2]uint64, n)
+	for i, key := range names {
+		open.SetUsingHashAndKey(hashes[i], key, i)
+		chained.set(hashes[i], key, i)
+		words[i][0], words[i][1] = KeyWords(key)
+	}
+	b.Run("lookup/open", func(b *testing.B) {
+		for i := range b.N {
+			k := i % n
+			if _, ok := open.GetUsingHashAndWords(hashes[k], words[k][0], words[k][1], names[k]); !ok {
+				b.Fatal("key not found")
+			}
+		}
+	})
+	b.Run("lookup/chained", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := chained.get(hashes[i%n], names[i%n]); !ok {
+				b.Fatal("key not found")
+			}
+		}
+	})
+}
+
+// getBytesEqual is GetUsingHashAndKey as it was before entries kept the
+// first words of their key: the key bytes compared on every hash hit.
+func (m *Map[K, V]) getBytesEqual(hash uint64, key []byte) (V, bool) {
+	mask := uint64(len(m.entries) - 1)
+	for i, step := HashToIndex(hash, mask+1), uint64(1); m.entries[i].mid != 0; step, i = step+1, (i+step)&mask {
+		e := &m.entries[i]
+		if e.key == hash && int(e.nameLength) == len(key) &&
+			(e.name == unsafe.Pointer(unsafe.SliceData(key)) || bytes.Equal(unsafe.Slice((*byte)(e.name), e.nameLength), key)) {
+			return m.cache[e.mid], true
+		}
+	}
+	return *new(V), false
+}
+
+// BenchmarkKeyCompare looks up as many names as the 413 stations of the
+// 1brc sample data, of 3 to 14 bytes and in random order like the rows of
+// a measurements file: by hash alone as the original map did, which told
+// colliding names apart by nothing, by their words with
+// GetUsingHashAndWords and GetUsingHashAndKey, and by bytes.Equal as before
+// the words. The keys are copies so no lookup matches by pointer.
+func BenchmarkKeyCompare(b *testing.B) {
+	const n = 413
+	names := make([][]byte, n)
+	m := NewHashMap[string, int](n, n*BucketsPerKey)
+	hashes := make([]uint64, n)
+	words := make([][2]uint64, n)
+	for i := range names {
+		names[i] = []byte(fmt.Sprintf("%d-%s", i, strings.Repeat("x", i%11)))
+		hashes[i] = HashBytes64(names[i])
+		m.SetUsingHashAndKey(hashes[i], names[i], i)
+		words[i][0], words[i][1] = KeyWords(names[i])
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	order := make([]int, 1<<16)
+	lookups := make([][]byte, len(order))
+	for i := range order {
+		order[i] = rng.IntN(n)
+		lookups[i] = append([]byte(nil), names[order[i]]...)
+	}
+	mask := len(order) - 1
+
+	b.Run("hash", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := m.GetUsingHash(hashes[order[i&mask]]); !ok {
+				b.Fatal("key not found")
+			}
+		}
+	})
+	b.Run("words", func(b *testing.B) {
+		for i := range b.N {
+			k := order[i&mask]
+			if _, ok := m.GetUsingHashAndWords(hashes[k], words[k][0], words[k][1], lookups[i&mask]); !ok {
+				b.Fatal("key not found")
+			}
+		}
+	})
+	b.Run("key", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := m.GetUsingHashAndKey(hashes[order[i&mask]], lookups[i&mask]); !ok {
+				b.Fatal("key not found")
+			}
+		}
+	})
+	b.Run("bytes", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := m.getBytesEqual(hashes[order[i&mask]], lookups[i&mask]); !ok {
+				b.Fatal("key not found")
+			}
+		}
+	})
+}
@@ -0,0 +1,182 @@
+// Package onebrc aggregates One Billion Row Challenge measurements, lines
+// of station;temperature, into the min, mean and max of every station. The
+// 1brc command in cmd/1brc runs Main.
+//
+// Aggregate and its variants below return errors rather than exiting, and
+// Main, -serve and the tests all go through them. Other programs can call
+// them too and read the StationData of every station. The options they
+// honor, such as the delimiter, -strict or -decimals, are package-level
+// variables that only Main sets from its flags, so callers get the format
+// of the 1brc spec: a ';' delimiter and one fractional digit. The parser
+// also keeps per-run counters in package variables, so the functions take
+// runMu for the whole run: they are safe to call from several goroutines,
+// which then wait for each other, each run still using all its workers.
+package onebrc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// runMu serializes runs, which share the per-run state reset by beginRun.
+var runMu sync.Mutex
+
+// beginRun resets the per-run counters for a run that holds runMu.
+func beginRun() {
+	tooManyStations.Store(false)
+	malformedTotal.Store(0)
+	coveredBytes.Store(0)
+	inputBase = 0
+	lastRun = runStats{}
+}
+
+// Aggregate reads the measurements at path ("-" for stdin) using the given
+// number of parser workers and returns the per-station results keyed by
+// station name. Failures to open, stat or map the input are returned rather
+// than terminating the process. Canceling ctx stops the workers after the
+// chunks they hold and returns ctx.Err() once the input is unmapped.
+func Aggregate(ctx context.Context, path string, workers int) (map[string]*StationData, error) {
+	return AggregateFiles(ctx, []string{path}, workers)
+}
+
+// AggregateFiles is like Aggregate but merges several inputs, such as the
+// shards of one dataset, into a single result. Each file is mapped and
+// split across the workers on its own, so chunk offsets never mix files.
+func AggregateFiles(ctx context.Context, paths []string, workers int) (map[string]*StationData, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("invalid worker count %d: must be at least 1", workers)
+	}
+
+	runMu.Lock()
+	defer runMu.Unlock()
+	beginRun()
+	finalResult := make(map[string]*StationData, maxNameNum)
+	for _, path := range paths {
+		if err := createWorkers(ctx, path, workers, finalResult, nil); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if checkStations(len(finalResult)); tooManyStations.Load() {
+			return nil, errTooManyStations(path)
+		}
+	}
+	return finalResult, nil
+}
+
+// AggregateReader is like Aggregate for measurements read from r, such as a
+// network body. r is parsed in blocks as it arrives and never held whole.
+func AggregateReader(ctx context.Context, r io.Reader, workers int) (map[string]*StationData, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("invalid worker count %d: must be at least 1", workers)
+	}
+
+	runMu.Lock()
+	defer runMu.Unlock()
+	beginRun()
+	finalResult := make(map[string]*StationData, maxNameNum)
+	var report *strictReport
+	if strictMode {
+		report = &strictReport{}
+		defer report.print("input")
+	}
+	if err := createStreamWorkers(ctx, r, workers, finalResult, report); err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if checkStations(len(finalResult)); tooManyStations.Load() {
+		return nil, errTooManyStations("input")
+	}
+	return finalResult, nil
+}
+
+// AggregateBytes is like Aggregate for measurements already in memory, so
+// parsing can be measured and tested without files or mmap. data is only
+// read; worker counts below 1 are treated as 1.
+func AggregateBytes(data []byte, workers int) map[string]*StationData {
+	runMu.Lock()
+	defer runMu.Unlock()
+	beginRun()
+	finalResult := make(map[string]*StationData, maxNameNum)
+	var report *strictReport
+	if strictMode {
+		report = &strictReport{}
+		defer report.print("input")
+	}
+	if skipHeader {
+		data = cutHeader(data, report)
+	}
+	aggregateData(context.Background(), data, max(workers, 1), finalResult, report)
+	return finalResult
+}
+
+// AggregateStream is like Aggregate but hands every station to emit in
+// name order instead of returning a map. For a file that can be mapped the
+// stations come straight from the merged worker maps: no map of every name
+// is built, only the slice of stations that is sorted, so the caller can
+// write results out incrementally. Streamed inputs, such as stdin or gzip,
+// are still merged into a map first. Nothing is emitted when aggregation
+// fails or ctx is canceled. emit runs while the run holds runMu, so it must
+// not aggregate anything itself.
+func AggregateStream(ctx context.Context, path string, workers int, emit func(name string, s *StationData)) error {
+	if workers < 1 {
+		return fmt.Errorf("invalid worker count %d: must be at least 1", workers)
+	}
+
+	runMu.Lock()
+	defer runMu.Unlock()
+	beginRun()
+	finalResult := make(map[string]*StationData)
+	if err := createWorkers(ctx, path, workers, finalResult, emit); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if checkStations(len(finalResult)); tooManyStations.Load() {
+		return errTooManyStations(path)
+	}
+
+	names := make([]string, 0, len(finalResult))
+	for name := range finalResult {
+		names = append(names, name)
+	}
+	radixSortStrings(names)
+	for _, name := range names {
+		emit(name, finalResult[name])
+	}
+	return nil
+}
+
+// emitSorted hands the stations parseData found in data to emit in name
+// order, after merging the stations of the last lines into those of the
+// chunks with the same name.
+func emitSorted(data []byte, stations []*StationData, tail map[string]*StationData, emit func(name string, s *StationData)) {
+	for _, s := range stations {
+		name := stationName(data, s)
+		if ignoreCase {
+			name = lowerFolded(name)
+		}
+		s.name = string(name)
+		if t, ok := tail[s.name]; ok {
+			mergeInto(s, t)
+			delete(tail, s.name)
+		}
+	}
+	for _, t := range tail {
+		stations = append(stations, t)
+	}
+	slices.SortFunc(stations, func(a, b *StationData) int {
+		return strings.Compare(a.name, b.name)
+	})
+	for _, s := range stations {
+		emit(s.name, s)
+	}
+}
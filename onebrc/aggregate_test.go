@@ -0,0 +1,192 @@
+package onebrc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// streamed runs AggregateStream on path and formats the stations in the
+// order they were emitted, as printResults would format them all at once.
+func streamed(t *testing.T, path string, workers int) string {
+	t.Helper()
+	var stations []string
+	err := AggregateStream(context.Background(), path, workers, func(name string, s *StationData) {
+		one := format(t, map[string]*StationData{name: s})
+		stations = append(stations, strings.TrimSuffix(strings.TrimPrefix(one, "{"), "}\n"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "{" + strings.Join(stations, ", ") + "}\n"
+}
+
+// The stations AggregateStream emits, one by one, make up the output of a
+// normal run, including stations only found in the last lines, which are
+// parsed apart from the chunks.
+func TestAggregateStream(t *testing.T) {
+	input := string(manyStations(400_000, 700)) + "Zurich;1.5\nstation-000001;12.0\nAbha;-3.0"
+	path := writeInput(t, input)
+	want := reference(t, input)
+
+	for _, shared := range []bool{false, true} {
+		setFlag(t, &useSharedMap, shared)
+		for _, workers := range []int{1, 4} {
+			if got := streamed(t, path, workers); got != want {
+				t.Errorf("shared map %v, %d workers: emitted %.200q..., want %.200q...", shared, workers, got, want)
+			}
+		}
+	}
+
+	// gzip is streamed into a map before it is emitted
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	fmt.Fprint(zw, input)
+	zw.Close()
+	gzPath := path + ".gz"
+	if err := os.WriteFile(gzPath, gz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := streamed(t, gzPath, 4); got != want {
+		t.Errorf("gzip: emitted %.200q..., want %.200q...", got, want)
+	}
+}
+
+// cancelAfter is a context canceled by the n-th call of its Err, so a run
+// is canceled mid-way without depending on timing.
+type cancelAfter struct {
+	context.Context
+	cancel context.CancelFunc
+	calls  atomic.Int64
+	n      int64
+}
+
+func newCancelAfter(n int64) *cancelAfter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &cancelAfter{Context: ctx, cancel: cancel, n: n}
+}
+
+func (c *cancelAfter) Err() error {
+	if c.calls.Add(1) == c.n {
+		c.cancel()
+	}
+	return c.Context.Err()
+}
+
+// cancelingReader cancels its context once it has handed out past bytes.
+type cancelingReader struct {
+	r      io.Reader
+	past   int
+	read   int
+	cancel context.CancelFunc
+}
+
+func (c *cancelingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.read += n; c.read > c.past {
+		c.cancel()
+	}
+	return n, err
+}
+
+// checkNoLeaks fails t if the goroutine count doesn't get back to before
+// within a second.
+func checkNoLeaks(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left running, %d before:\n%s",
+				runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Canceling the context mid-run returns context.Canceled and leaves no
+// worker, queue or reader goroutine behind.
+func TestCancelMidRunLeavesNoGoroutines(t *testing.T) {
+	setFlag(t, &chunkSize, 4096)
+	path := writeInput(t, string(sample(t, 200_000)))
+
+	for _, shared := range []bool{false, true} {
+		setFlag(t, &useSharedMap, shared)
+		for _, workers := range []int{1, 4} {
+			before := runtime.NumGoroutine()
+			ctx := newCancelAfter(20)
+			if _, err := Aggregate(ctx, path, workers); !errors.Is(err, context.Canceled) {
+				t.Fatalf("shared map %v, %d workers: got %v, want context.Canceled", shared, workers, err)
+			}
+			if calls := ctx.calls.Load(); calls < ctx.n {
+				t.Fatalf("shared map %v, %d workers: finished after %d context checks, before canceling", shared, workers, calls)
+			}
+			checkNoLeaks(t, before)
+
+			before = runtime.NumGoroutine()
+			ctx = newCancelAfter(20)
+			err := AggregateStream(ctx, path, workers, func(string, *StationData) {})
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("stream, shared map %v, %d workers: got %v, want context.Canceled", shared, workers, err)
+			}
+			checkNoLeaks(t, before)
+		}
+	}
+
+	// the reader path hands out streamChunkSize blocks, so it needs a few
+	data := bytes.Repeat([]byte("Hamburg;12.0\nBulawayo;8.9\n"), 4*streamChunkSize/26)
+	for _, workers := range []int{1, 4} {
+		before := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &cancelingReader{r: bytes.NewReader(data), past: streamChunkSize, cancel: cancel}
+		if _, err := AggregateReader(ctx, r, workers); !errors.Is(err, context.Canceled) {
+			t.Fatalf("reader, %d workers: got %v, want context.Canceled", workers, err)
+		}
+		if r.read == len(data) {
+			t.Errorf("reader, %d workers: read all %d bytes after canceling", workers, r.read)
+		}
+		checkNoLeaks(t, before)
+	}
+}
+
+// Calls from several goroutines must not share a run's state; go test -race
+// reports it if they do. Each input is large enough for several workers and
+// chunks.
+func TestConcurrentAggregate(t *testing.T) {
+	inputs := []string{string(manyStations(300_000, 500)), string(sample(t, 300_000))}
+	var wants, paths []string
+	for _, input := range inputs {
+		wants = append(wants, reference(t, input))
+		paths = append(paths, writeInput(t, input))
+	}
+
+	results := make([]map[string]*StationData, len(paths))
+	errs := make([]error, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = Aggregate(context.Background(), path, 4)
+		}()
+	}
+	wg.Wait()
+	for i := range paths {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if got := format(t, results[i]); got != wants[i] {
+			t.Errorf("input %d: got %.80q, want %.80q", i, got, wants[i])
+		}
+	}
+}
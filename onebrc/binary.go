@@ -0,0 +1,35 @@
+package onebrc
+
+import "fmt"
+
+// bytes at the start of every input checked by looksBinary
+const binarySample = 4096
+
+// forceBinary parses inputs even when they look binary, see -force.
+var forceBinary = false
+
+// looksBinary reports whether sample, the start of an input, is unlikely to
+// be measurements: it holds a NUL byte, or more than one in ten bytes are
+// control characters other than tab, CR and LF. Bytes of UTF-8 names are at
+// or above 0x80 and don't count.
+func looksBinary(sample []byte) bool {
+	control := 0
+	for _, b := range sample {
+		switch {
+		case b == 0:
+			return true
+		case b < ' ' && b != '\t' && b != '\r' && b != '\n', b == 0x7f:
+			control++
+		}
+	}
+	return control*10 > len(sample)
+}
+
+// checkBinary returns an error when data, an input or its first block,
+// looks binary and -force isn't set.
+func checkBinary(data []byte) error {
+	if !forceBinary && looksBinary(data[:min(len(data), binarySample)]) {
+		return fmt.Errorf("input looks binary, not measurements; use -force to parse it anyway")
+	}
+	return nil
+}
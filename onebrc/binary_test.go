@@ -0,0 +1,33 @@
+package onebrc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// A binary file is rejected with an error, mapped or on stdin, instead of
+// being aggregated into garbage stations; -force parses it anyway, and
+// UTF-8 names or tabs don't trip the check.
+func TestBinaryInputRejected(t *testing.T) {
+	elf := append([]byte("\x7fELF\x02\x01\x01\x00"), bytes.Repeat([]byte("Hamburg;12.0\n"), 100)...)
+	controls := bytes.Repeat([]byte("\x01\x02\x03;1.0\n"), 100)
+	for name, input := range map[string][]byte{"NUL byte": elf, "control characters": controls} {
+		path := writeInput(t, string(input))
+		stdout, stderr, code := runMain(t, path)
+		if code == 0 || stdout != "" || !strings.Contains(stderr, "looks binary") {
+			t.Errorf("%s: exit %d, stdout %.100q, stderr %q; want a binary input error", name, code, stdout, stderr)
+		}
+		if _, stderr, code := runMainStdin(t, string(input), "-"); code == 0 || !strings.Contains(stderr, "looks binary") {
+			t.Errorf("%s on stdin: exit %d, stderr %q; want a binary input error", name, code, stderr)
+		}
+		if _, stderr, code := runMain(t, "-force", path); code != 0 {
+			t.Errorf("%s with -force: exit %d, stderr %q", name, code, stderr)
+		}
+	}
+
+	input := "München;12.0\nZürich\t;3.4\nSão Paulo;25.1\r\n"
+	if got, stderr, code := runMain(t, writeInput(t, input)); code != 0 || !strings.Contains(got, "München=12.0/12.0/12.0") {
+		t.Errorf("text input: got %q, exit %d, stderr %q", got, code, stderr)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package onebrc
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// blockDeviceSize returns the size of the block device open as file with
+// the BLKGETSIZE64 ioctl; stat reports 0 for devices. Opening a raw disk or
+// partition usually takes root or membership of the disk group.
+func blockDeviceSize(file *os.File) (int64, error) {
+	var size uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), unix.BLKGETSIZE64, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, os.NewSyscallError("ioctl BLKGETSIZE64", errno)
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package onebrc
+
+import (
+	"errors"
+	"os"
+)
+
+// blockDeviceSize is only implemented on Linux; elsewhere block devices
+// are streamed.
+func blockDeviceSize(file *os.File) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
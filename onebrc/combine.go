@@ -0,0 +1,78 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// one name=min/mean/max/count entry of -with-count output; the name is
+// matched lazily so it may itself contain '=', '/' or ", "
+var partialEntry = regexp.MustCompile(`(.+?)=(-?[0-9.]+)/(-?[0-9.]+)/(-?[0-9.]+)/([0-9]+)(?:, |$)`)
+
+// combineFiles merges partial results, text output written with
+// -with-count, into one result as if their inputs had been aggregated
+// together. The sum of each partial is recovered as mean*count, so combined
+// means can be off by up to half a unit of the last printed digit.
+func combineFiles(paths []string) (map[string]*StationData, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+	beginRun()
+	lastRun.workers = 1
+	finalResult := make(map[string]*StationData, maxNameNum)
+	for _, path := range paths {
+		if err := combineFile(path, finalResult); err != nil {
+			return nil, err
+		}
+	}
+	return finalResult, nil
+}
+
+func combineFile(path string, finalResult map[string]*StationData) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s file: %w", path, err)
+	}
+	lastRun.bytes += uint64(len(data))
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' || data[len(data)-1] != '}' {
+		return fmt.Errorf("failed to parse %s file: not {name=min/mean/max/count, ...} output", path)
+	}
+	entries := string(data[1 : len(data)-1])
+
+	parsed := 0
+	for _, m := range partialEntry.FindAllStringSubmatch(entries, -1) {
+		parsed += len(m[0])
+		minTemp, errMin := strconv.ParseFloat(m[2], 64)
+		mean, errMean := strconv.ParseFloat(m[3], 64)
+		maxTemp, errMax := strconv.ParseFloat(m[4], 64)
+		count, errCount := strconv.ParseInt(m[5], 10, 64)
+		if errMin != nil || errMean != nil || errMax != nil || errCount != nil {
+			return fmt.Errorf("failed to parse %s file: bad entry %q", path, m[0])
+		}
+		// names are matched as the parser matches them, folded for
+		// -ignore-case and trimmed for -trim-names
+		name := []byte(m[1])
+		if trimNames {
+			name = bytes.Trim(name, " \t")
+		}
+		mergeStationName(finalResult, name, &StationData{
+			MinTemp: fixedPoint(minTemp),
+			MaxTemp: fixedPoint(maxTemp),
+			Sum:     fixedPoint(mean * float64(count)),
+			Count:   count,
+		})
+	}
+	if parsed != len(entries) {
+		return fmt.Errorf("failed to parse %s file: entries must be name=min/mean/max/count, see -with-count", path)
+	}
+	return nil
+}
+
+// fixedPoint converts degrees to the 1/tempScale units of StationData.
+func fixedPoint(f float64) int64 {
+	return int64(math.Round(f * float64(tempScale)))
+}
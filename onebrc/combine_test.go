@@ -0,0 +1,112 @@
+package onebrc
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// combineParts runs -with-count over each part and -combine over their
+// outputs, with flags, and returns what -combine printed.
+func combineParts(t *testing.T, flags []string, parts ...[]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	args := append([]string{"-combine", "-with-count"}, flags...)
+	for i, part := range parts {
+		out, stderr, code := runMain(t, "-with-count", writeInput(t, string(part)))
+		if code != 0 {
+			t.Fatalf("part %d: exit %d, stderr %q", i, code, stderr)
+		}
+		path := filepath.Join(dir, "part"+strconv.Itoa(i)+".txt")
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, path)
+	}
+	got, stderr, code := runMain(t, args...)
+	if code != 0 {
+		t.Fatalf("-combine: exit %d, stderr %q", code, stderr)
+	}
+	return got
+}
+
+// When every partial mean is printed exactly, -combine prints what a single
+// run over the concatenated parts does.
+func TestCombineMatchesSingleRun(t *testing.T) {
+	parts := [][]byte{
+		[]byte("Hamburg;12.0\nHamburg;14.0\nBulawayo;8.9\nSt. John's;-1.5\n"),
+		[]byte("Hamburg;-3.4\nPalembang;38.8\nSt. John's;-2.5\nSt. John's;-0.5\n"),
+		[]byte("Bulawayo;-8.9\nHamburg;20.0\nHamburg;0.2\nCracow;12.6\n"),
+	}
+	want, stderr, code := runMain(t, "-with-count", writeInput(t, string(bytes.Join(parts, nil))))
+	if code != 0 {
+		t.Fatalf("single run: exit %d, stderr %q", code, stderr)
+	}
+	if got := combineParts(t, nil, parts...); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// -ignore-case and -trim-names merge the names of partials aggregated
+// without them as a single run with them merges the names of the input.
+func TestCombineMatchesNames(t *testing.T) {
+	parts := [][]byte{
+		[]byte("Hamburg;12.0\nhamburg;14.0\n Bulawayo;8.9\n"),
+		[]byte("HAMBURG;-3.4\nBulawayo\t;-8.9\nbulawayo;1.1\n"),
+	}
+	for _, flag := range []string{"-ignore-case", "-trim-names"} {
+		want, stderr, code := runMain(t, "-with-count", flag, writeInput(t, string(bytes.Join(parts, nil))))
+		if code != 0 {
+			t.Fatalf("%s single run: exit %d, stderr %q", flag, code, stderr)
+		}
+		if got := combineParts(t, []string{flag}, parts...); got != want {
+			t.Errorf("%s: got %q, want %q", flag, got, want)
+		}
+	}
+}
+
+var combinedEntry = regexp.MustCompile(`([^=,{]+)=(-?[0-9.]+)/(-?[0-9.]+)/(-?[0-9.]+)/([0-9]+)`)
+
+// On generated parts the means are recovered from rounded partial means, so
+// they may differ from a single run by one unit of the last digit; names,
+// min, max and counts must match exactly.
+func TestCombineGeneratedParts(t *testing.T) {
+	data := sample(t, 30_000)
+	var parts [][]byte
+	for i, cut := 0, 0; i < 3; i++ {
+		end := len(data)
+		if i < 2 {
+			end = cut + len(data)/3
+			end += bytes.IndexByte(data[end:], '\n') + 1
+		}
+		parts = append(parts, data[cut:end])
+		cut = end
+	}
+	want, stderr, code := runMain(t, "-with-count", writeInput(t, string(data)))
+	if code != 0 {
+		t.Fatalf("single run: exit %d, stderr %q", code, stderr)
+	}
+	got := combineParts(t, nil, parts...)
+
+	gotEntries := combinedEntry.FindAllStringSubmatch(got, -1)
+	wantEntries := combinedEntry.FindAllStringSubmatch(want, -1)
+	if len(gotEntries) != len(wantEntries) || len(wantEntries) == 0 {
+		t.Fatalf("got %d stations, want %d", len(gotEntries), len(wantEntries))
+	}
+	for i, w := range wantEntries {
+		g := gotEntries[i]
+		if g[1] != w[1] || g[2] != w[2] || g[4] != w[4] || g[5] != w[5] {
+			t.Errorf("got %s, want %s", g[0], w[0])
+			continue
+		}
+		gotMean, _ := strconv.ParseFloat(g[3], 64)
+		wantMean, _ := strconv.ParseFloat(w[3], 64)
+		if math.Abs(gotMean-wantMean) > 0.1+1e-9 {
+			t.Errorf("got %s, want %s", g[0], w[0])
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package onebrc
+
+import (
+	"bytes"
+	"sync"
+	"unsafe"
+
+	"github.com/nbukhari/1brc/internal/fasthash"
+)
+
+const (
+	// number of lock stripes in a ConcurrentMap, a power of 2
+	concurrentShards = 1 << 8
+	// bytes of a cache line, which every mapShard fills on its own
+	cacheLineSize = 64
+	// bytes of a mapShard's fields
+	shardFieldsSize = unsafe.Sizeof(sync.Mutex{}) + unsafe.Sizeof(map[uint64][]any(nil)) + unsafe.Sizeof(0)
+)
+
+// useSharedMap makes all workers of a mapped file aggregate into one
+// ConcurrentMap instead of a Map each. Streamed input is unaffected.
+//
+// It trades speed for memory: there is one StationData per station instead
+// of one per station per worker, and no duplicate-merging pass at the end,
+// but every row takes a stripe lock. BenchmarkSharedMap over 5M rows with
+// -cpu 1,2,4,8, a worker per GOMAXPROCS, took per run:
+//
+//	GOMAXPROCS  per-worker  shared
+//	1           126-134ms   323-425ms
+//	2           121-208ms   309-433ms
+//	4           118-156ms   374-439ms
+//	8           146-232ms   355-439ms
+//
+// Per-worker maps won at every count. The host had a single core, so above
+// 1 the workers were time-sliced rather than parallel: the shared map paid
+// for its locks and for workers preempted while holding a stripe, but not
+// for cache lines bouncing between cores, which would only widen the gap.
+// Use the shared map only when memory, not time, is the constraint.
+var useSharedMap = false
+
+// ConcurrentMap is a hash-keyed map that many goroutines can update at once.
+// Keys are spread over striped shards, each guarded by its own mutex.
+type ConcurrentMap[V any] struct {
+	shards [concurrentShards]mapShard[V]
+}
+
+type mapShard[V any] struct {
+	sync.Mutex
+	entries map[uint64][]V
+	// number of values in entries
+	n int
+	// keep neighbouring locks on separate cache lines; no pad when the
+	// fields already end on one
+	_ [(cacheLineSize - shardFieldsSize%cacheLineSize) % cacheLineSize]byte
+}
+
+func NewConcurrentMap[V any]() *ConcurrentMap[V] {
+	m := &ConcurrentMap[V]{}
+	for i := range m.shards {
+		m.shards[i].entries = make(map[uint64][]V)
+	}
+	return m
+}
+
+// Lock locks and returns the shard that owns hash. The caller must Unlock it.
+func (m *ConcurrentMap[V]) Lock(hash uint64) *mapShard[V] {
+	s := &m.shards[fasthash.HashToIndex(hash, concurrentShards)]
+	s.Lock()
+	return s
+}
+
+// GetUsingHashFunc returns the first value stored under hash for which eq
+// reports true. The shard must be locked.
+func (s *mapShard[V]) GetUsingHashFunc(hash uint64, eq func(V) bool) (V, bool) {
+	for _, v := range s.entries[hash] {
+		if eq(v) {
+			return v, true
+		}
+	}
+	return *new(V), false
+}
+
+// SetUsingHash adds value under hash. The shard must be locked.
+func (s *mapShard[V]) SetUsingHash(hash uint64, value V) {
+	s.entries[hash] = append(s.entries[hash], value)
+	s.n++
+}
+
+// Len returns the number of values in the map, locking every shard in turn.
+func (m *ConcurrentMap[V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		m.shards[i].Lock()
+		n += m.shards[i].n
+		m.shards[i].Unlock()
+	}
+	return n
+}
+
+// Range calls fn for every value. It must not run concurrently with writers.
+func (m *ConcurrentMap[V]) Range(fn func(V)) {
+	for i := range m.shards {
+		for _, values := range m.shards[i].entries {
+			for _, v := range values {
+				fn(v)
+			}
+		}
+	}
+}
+
+// readShared is the shared-map counterpart of readUsingMMAP. Every row is
+// recorded under its stripe lock, so a single scanner is used; interleaving
+// several would only hold more locks at once. New stations come from slab,
+// the calling worker's.
+func readShared(data []byte, results *ConcurrentMap[*StationData], slab *stationSlab, offset uint64, bytesToRead uint64, maxAvailable uint64) {
+	scanner := &Scanner{pointer: unsafe.Pointer(&data[0]), position: offset, end: maxAvailable}
+	segmentStart, segmentEnd := segmentBounds(scanner, offset, bytesToRead, maxAvailable)
+	scanner.position = segmentStart
+	scanner.end = segmentEnd
+
+	for scanner.hasNext() {
+		word := scanner.getLong()
+		pos := findDelimiter(word)
+		wordB := scanner.getLongAt(scanner.pos() + 8)
+		posB := findDelimiter(wordB)
+		nameAddress := scanner.pos()
+		lineStart := nameAddress
+		hash, ok := hashName(word, pos, wordB, posB, scanner)
+		if !ok {
+			continue
+		}
+		nameLength := int(scanner.pos() - nameAddress)
+		if trimNames {
+			nameAddress, nameLength = trimName(scanner, nameAddress, nameLength)
+		}
+		if ignoreCase {
+			hash = hashFolded(scanner.getBytesAt(nameAddress, nameLength))
+		} else if nameHash != nil {
+			hash = nameHash(scanner.getBytesAt(nameAddress, nameLength))
+		}
+		temp := scanNumber(scanner)
+
+		shard := results.Lock(hash)
+		station, ok := shard.GetUsingHashFunc(hash, func(s *StationData) bool {
+			return scanner.nameEquals(s, nameAddress, nameLength)
+		})
+		if !ok {
+			// a line without a delimiter, see findResult
+			if bytes.IndexByte(scanner.getBytesAt(nameAddress, nameLength), '\n') >= 0 {
+				shard.Unlock()
+				skipLine(scanner, lineStart)
+				continue
+			}
+			station = newStation(slab, nameAddress, nameLength)
+			shard.SetUsingHash(hash, station)
+		}
+		record(station, temp)
+		shard.Unlock()
+	}
+
+	if verifyCoverage {
+		addCoverage(scanner.pos() - segmentStart)
+	}
+}
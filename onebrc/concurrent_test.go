@@ -0,0 +1,38 @@
+package onebrc
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+// A shard fills whole cache lines, no more than it needs, so neighbouring
+// locks never share one.
+func TestShardSize(t *testing.T) {
+	size := unsafe.Sizeof(mapShard[*StationData]{})
+	if size%cacheLineSize != 0 || size-shardFieldsSize >= cacheLineSize {
+		t.Errorf("mapShard is %d bytes for %d bytes of fields, want the next multiple of %d",
+			size, shardFieldsSize, cacheLineSize)
+	}
+}
+
+// BenchmarkSharedMap compares per-worker maps merged at the end with one
+// ConcurrentMap shared by all workers, see useSharedMap, with a worker per
+// GOMAXPROCS; run it with -cpu 1,2,4,8 to compare core counts.
+func BenchmarkSharedMap(b *testing.B) {
+	data := sample(b, 5_000_000)
+	for _, shared := range []bool{false, true} {
+		name := "per-worker"
+		if shared {
+			name = "shared"
+		}
+		b.Run(name, func(b *testing.B) {
+			setFlag(b, &useSharedMap, shared)
+			workers := runtime.GOMAXPROCS(0)
+			b.SetBytes(int64(len(data)))
+			for range b.N {
+				AggregateBytes(data, workers)
+			}
+		})
+	}
+}
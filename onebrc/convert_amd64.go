@@ -0,0 +1,7 @@
+package onebrc
+
+// convertIntoNumberArch is convertIntoNumber in assembly, see
+// convert_amd64.s. Nothing calls it but TestConvertIntoNumberArch and
+// BenchmarkConvertIntoNumber: it is kept to compare against the Go version,
+// which the compiler inlines and scanNumber uses, see convertIntoNumber.
+func convertIntoNumberArch(decimalSepPos int, numberWord int64) int64
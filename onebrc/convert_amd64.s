@@ -0,0 +1,40 @@
+#include "textflag.h"
+
+// func convertIntoNumberArch(decimalSepPos int, numberWord int64) int64
+//
+// The instructions of convertIntoNumber, one per line of its Go source.
+TEXT ·convertIntoNumberArch(SB), NOSPLIT, $0-24
+	MOVQ decimalSepPos+0(FP), CX
+	MOVQ numberWord+8(FP), AX
+
+	// shift := 28 - decimalSepPos
+	NEGQ CX
+	ADDQ $28, CX
+
+	// signed := ^(numberWord << 59) >> 63
+	MOVQ AX, DX
+	SHLQ $59, DX
+	NOTQ DX
+	SARQ $63, DX
+
+	// designMask := ^(signed & 0xFF)
+	MOVQ DX, BX
+	ANDQ $0xFF, BX
+	NOTQ BX
+
+	// digits := ((numberWord & designMask) << shift) & 0x0F000F0F00
+	ANDQ BX, AX
+	SHLQ CX, AX
+	MOVQ $0x0F000F0F00, BX
+	ANDQ BX, AX
+
+	// absValue := ((digits * 0x640a0001) >> 32) & 0x3FF
+	IMULQ $0x640a0001, AX
+	SHRQ $32, AX
+	ANDQ $0x3FF, AX
+
+	// (absValue ^ signed) - signed
+	XORQ DX, AX
+	SUBQ DX, AX
+	MOVQ AX, ret+16(FP)
+	RET
@@ -0,0 +1,9 @@
+//go:build !amd64
+
+package onebrc
+
+// convertIntoNumberArch is convertIntoNumber where there is no assembly
+// version, see convert_amd64.go; it only exists for the comparison there.
+func convertIntoNumberArch(decimalSepPos int, numberWord int64) int64 {
+	return convertIntoNumber(decimalSepPos, numberWord)
+}
@@ -0,0 +1,186 @@
+package onebrc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+// numberWord loads the reading s as scanNumber does, the 8 bytes after the
+// delimiter, and returns it with the bit position of its separator.
+func numberWord(s string) (int, int64) {
+	var buf [8]byte
+	copy(buf[:], s+"\n")
+	word := binary.LittleEndian.Uint64(buf[:])
+	return bits.TrailingZeros64(^word & 0x10101000), int64(word)
+}
+
+// reading formats tenths (or hundredths with decimals 2) the way the
+// inputs write them, with sep before the fraction.
+func reading(v int, decimals int, sep byte) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	unit := 10
+	if decimals == 2 {
+		unit = 100
+	}
+	return fmt.Sprintf("%s%d%c%0*d", sign, v/unit, sep, decimals, v%unit)
+}
+
+func TestConvertIntoNumber(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+	}{
+		{"0.0", 0},
+		{"-0.0", 0},
+		{"0.1", 1},
+		{"-0.1", -1},
+		{"9.9", 99},
+		{"-9.9", -99},
+		{"10.0", 100},
+		{"-10.0", -100},
+		{"99.9", 999},
+		{"-99.9", -999},
+		{"12,3", 123},
+		{"-12,3", -123},
+	} {
+		if got := convertIntoNumber(numberWord(tc.in)); got != tc.want {
+			t.Errorf("convertIntoNumber(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+
+	// every reading of the 1brc range, with either separator
+	for _, sep := range []byte{'.', ','} {
+		for v := MIN_TEMP; v <= MAX_TEMP; v++ {
+			in := reading(v, 1, sep)
+			if got := convertIntoNumber(numberWord(in)); got != int64(v) {
+				t.Fatalf("convertIntoNumber(%q) = %d, want %d", in, got, v)
+			}
+		}
+	}
+}
+
+// The assembly must not drift from the Go it was written from: both
+// convert every reading of the 1brc range, -0.0 and either separator alike.
+func TestConvertIntoNumberArch(t *testing.T) {
+	for _, sep := range []byte{'.', ','} {
+		for v := MIN_TEMP; v <= MAX_TEMP; v++ {
+			in := reading(v, 1, sep)
+			if got, want := convertIntoNumberArch(numberWord(in)), convertIntoNumber(numberWord(in)); got != want {
+				t.Fatalf("convertIntoNumberArch(%q) = %d, convertIntoNumber = %d", in, got, want)
+			}
+		}
+	}
+	if got := convertIntoNumberArch(numberWord("-0.0")); got != 0 {
+		t.Errorf("convertIntoNumberArch(%q) = %d, want 0", "-0.0", got)
+	}
+}
+
+var convertSink int64
+
+// BenchmarkConvertIntoNumber converts every reading from -99.9 to 99.9 per
+// iteration, with the Go version and with convertIntoNumberArch.
+func BenchmarkConvertIntoNumber(b *testing.B) {
+	type word struct {
+		pos  int
+		word int64
+	}
+	var words []word
+	for v := MIN_TEMP; v <= MAX_TEMP; v++ {
+		pos, w := numberWord(reading(v, 1, '.'))
+		words = append(words, word{pos, w})
+	}
+	// direct calls, so the Go version is inlined as in scanNumber
+	b.Run("go", func(b *testing.B) {
+		var sum int64
+		for range b.N {
+			for _, w := range words {
+				sum += convertIntoNumber(w.pos, w.word)
+			}
+		}
+		convertSink = sum
+	})
+	b.Run("arch", func(b *testing.B) {
+		var sum int64
+		for range b.N {
+			for _, w := range words {
+				sum += convertIntoNumberArch(w.pos, w.word)
+			}
+		}
+		convertSink = sum
+	})
+}
+
+func TestConvertIntoNumber2(t *testing.T) {
+	if got := convertIntoNumber2(numberWord("-0.00")); got != 0 {
+		t.Errorf("convertIntoNumber2(%q) = %d, want 0", "-0.00", got)
+	}
+	for _, sep := range []byte{'.', ','} {
+		for v := -9999; v <= 9999; v++ {
+			in := reading(v, 2, sep)
+			if got := convertIntoNumber2(numberWord(in)); got != int64(v) {
+				t.Fatalf("convertIntoNumber2(%q) = %d, want %d", in, got, v)
+			}
+		}
+	}
+}
+
+// With -decimals 2 a reading with one fractional digit leaves the fast path
+// rather than reading the line break as its hundredths.
+func TestOneDigitFractionWithTwoDecimals(t *testing.T) {
+	t.Cleanup(func() { setDecimals(1) })
+	setDecimals(2)
+	input := strings.Repeat("A;12.3\nBerlin;1.00\nA;12.34\nA;-5.6\n", 50)
+	want := "{A=-5.60/6.35/12.34, Berlin=1.00/1.00/1.00}\n"
+	for _, workers := range []int{1, 4} {
+		if got := aggregate(t, input, workers); got != want {
+			t.Errorf("%d workers: got %q, want %q", workers, got, want)
+		}
+	}
+}
+
+// Integers and fractions without a leading zero miss the fast path and are
+// parsed by scanLenient, mixed with ordinary readings in one file.
+func TestIntegerAndBareFractionReadings(t *testing.T) {
+	input := "A;12\nA;12.3\nB;.5\nB;-.5\nC;-7\nC;0\nD;99\nD;-99\n" + strings.Repeat("E", 20) + ";-4\n"
+	want := "{A=12.0/12.2/12.3, B=-0.5/0.0/0.5, C=-7.0/-3.5/0.0, D=-99.0/0.0/99.0, " + strings.Repeat("E", 20) + "=-4.0/-4.0/-4.0}\n"
+	for _, workers := range []int{1, 4} {
+		if got := aggregate(t, input, workers); got != want {
+			t.Errorf("%d workers: got %q, want %q", workers, got, want)
+		}
+	}
+	results, err := AggregateReader(context.Background(), strings.NewReader(input), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := format(t, results); got != want {
+		t.Errorf("streamed: got %q, want %q", got, want)
+	}
+}
+
+func TestCommaDecimalSeparator(t *testing.T) {
+	input := "Hamburg;12.0\nBulawayo;8.9\nPalembang;-38.8\nHamburg;-3.4\nHamburg;7\n" + strings.Repeat("Bulawayo", 3) + ";.5\n"
+	want := "{Bulawayo=8.9/8.9/8.9, BulawayoBulawayoBulawayo=0.5/0.5/0.5, Hamburg=-3.4/5.2/12.0, Palembang=-38.8/-38.8/-38.8}\n"
+	comma := strings.ReplaceAll(input, ".", ",")
+
+	setFlag(t, &decimalSeparator, ',')
+	for _, workers := range []int{1, 4} {
+		if got := aggregate(t, comma, workers); got != want {
+			t.Errorf("%d workers: got %q, want %q", workers, got, want)
+		}
+	}
+
+	path := writeInput(t, comma)
+	if stdout, stderr, code := runMain(t, "-decimal-sep", ",", path); stdout != want || code != 0 {
+		t.Errorf("-decimal-sep ,: exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+	if _, _, code := runMain(t, "-decimal-sep", ",", "-delim", ",", path); code == 0 {
+		t.Error("-decimal-sep equal to -delim exited with 0")
+	}
+}
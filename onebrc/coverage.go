@@ -0,0 +1,31 @@
+package onebrc
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// verifyCoverage makes the mapped-file parsers count the bytes their
+// scanners actually step over, see -verify-coverage. The chunks must tile
+// the whole lines of the input exactly once; a mismatch means the
+// overlap or nextNewLine math dropped or reread lines.
+var verifyCoverage = false
+
+// coveredBytes sums the bytes consumed by every scanner of one input.
+var coveredBytes atomic.Uint64
+
+// addCoverage records that scanners consumed n bytes of a segment.
+func addCoverage(n uint64) {
+	coveredBytes.Add(n)
+}
+
+// reportCoverage logs the bytes consumed since the last report against
+// size, the length of the whole lines handed to the workers.
+func reportCoverage(size uint64) {
+	covered := coveredBytes.Swap(0)
+	if covered != size {
+		log.Printf("warning: coverage: workers consumed %d of %d bytes; chunk boundaries dropped or reread lines", covered, size)
+		return
+	}
+	log.Printf("coverage: workers consumed all %d bytes", size)
+}
@@ -0,0 +1,15 @@
+package onebrc
+
+import "runtime"
+
+// defaultWorkers is the worker count used without -workers: GOMAXPROCS,
+// which follows the GOMAXPROCS variable and the CPU affinity mask, capped by
+// the cgroup CPU quota so a container throttled to a few CPUs doesn't get a
+// worker per host CPU.
+func defaultWorkers() int {
+	n := runtime.GOMAXPROCS(0)
+	if quota := cpuQuota(); quota > 0 {
+		n = min(n, quota)
+	}
+	return n
+}
@@ -0,0 +1,84 @@
+//go:build linux
+
+package onebrc
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cpuQuota returns the CPUs the cgroup CPU quota allows, rounded up, or 0
+// when there is no quota or it can't be read. See cgroupQuota.
+func cpuQuota() int {
+	self, _ := os.ReadFile("/proc/self/cgroup")
+	return cgroupQuota("/sys/fs/cgroup", string(self))
+}
+
+// cgroupQuota returns the tightest CPU quota, in CPUs rounded up, of the
+// process's cgroup and its ancestors under root, the cgroup mount. self
+// lists the process's cgroups like /proc/self/cgroup. Without a private
+// cgroup namespace, as with systemd services or Kubernetes pods, the quota
+// is set on the process's own cgroup, not on root, which is only where the
+// walk ends. Both cgroup v2 (cpu.max) and v1 (cpu/cpu.cfs_*) are read.
+func cgroupQuota(root string, self string) int {
+	v2, v1 := "/", "/"
+	for _, line := range strings.Split(self, "\n") {
+		// hierarchy-ID:controllers:path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			v2 = fields[2]
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "cpu" {
+				v1 = fields[2]
+			}
+		}
+	}
+
+	quota := 0
+	tighten := func(cpus int) {
+		if cpus > 0 && (quota == 0 || cpus < quota) {
+			quota = cpus
+		}
+	}
+	for dir := path.Clean("/" + v2); ; dir = path.Dir(dir) {
+		if b, err := os.ReadFile(path.Join(root, dir, "cpu.max")); err == nil {
+			// "max 100000" or "<quota> <period>"
+			if fields := strings.Fields(string(b)); len(fields) == 2 {
+				tighten(quotaCPUs(fields[0], fields[1]))
+			}
+		}
+		if dir == "/" {
+			break
+		}
+	}
+	for dir := path.Clean("/" + v1); ; dir = path.Dir(dir) {
+		cfsQuota, errQuota := os.ReadFile(path.Join(root, "cpu", dir, "cpu.cfs_quota_us"))
+		cfsPeriod, errPeriod := os.ReadFile(path.Join(root, "cpu", dir, "cpu.cfs_period_us"))
+		if errQuota == nil && errPeriod == nil {
+			tighten(quotaCPUs(strings.TrimSpace(string(cfsQuota)), strings.TrimSpace(string(cfsPeriod))))
+		}
+		if dir == "/" {
+			break
+		}
+	}
+	return quota
+}
+
+// quotaCPUs divides quota by period, rounding up; "max" and -1 mean no quota.
+func quotaCPUs(quota, period string) int {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return int((q + p - 1) / p)
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package onebrc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCgroupFiles creates files, paths relative to root with their
+// contents, for a fake cgroup mount.
+func writeCgroupFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// The quota of a nested cgroup, the process's own as listed in
+// /proc/self/cgroup, is found, and the tightest along the way to the root
+// wins.
+func TestCgroupQuotaNested(t *testing.T) {
+	const pod = "/kubepods.slice/pod1/container1"
+	tests := []struct {
+		name  string
+		self  string
+		files map[string]string
+		want  int
+	}{
+		{"v2 own cgroup", "0::" + pod + "\n", map[string]string{
+			"cpu.max":                 "max 100000\n",
+			pod + "/cpu.max":          "150000 100000\n",
+			"/kubepods.slice/cpu.max": "max 100000\n",
+		}, 2},
+		{"v2 ancestor tighter", "0::" + pod + "\n", map[string]string{
+			pod + "/cpu.max":               "800000 100000\n",
+			"/kubepods.slice/pod1/cpu.max": "300000 100000\n",
+		}, 3},
+		{"v2 root only", "0::/\n", map[string]string{
+			"cpu.max": "400000 100000\n",
+		}, 4},
+		{"v2 no quota", "0::" + pod + "\n", map[string]string{
+			pod + "/cpu.max": "max 100000\n",
+		}, 0},
+		{"v1 own cgroup", "12:cpu,cpuacct:" + pod + "\n0::/\n", map[string]string{
+			"cpu" + pod + "/cpu.cfs_quota_us":  "250000\n",
+			"cpu" + pod + "/cpu.cfs_period_us": "100000\n",
+			"cpu/cpu.cfs_quota_us":             "-1\n",
+			"cpu/cpu.cfs_period_us":            "100000\n",
+		}, 3},
+		{"v1 root fallback", "", map[string]string{
+			"cpu/cpu.cfs_quota_us":  "100000\n",
+			"cpu/cpu.cfs_period_us": "100000\n",
+		}, 1},
+		{"nothing", "", nil, 0},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		writeCgroupFiles(t, root, tt.files)
+		if got := cgroupQuota(root, tt.self); got != tt.want {
+			t.Errorf("%s: got %d CPUs, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package onebrc
+
+// cpuQuota reports no quota off Linux.
+func cpuQuota() int {
+	return 0
+}
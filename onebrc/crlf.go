@@ -0,0 +1,10 @@
+package onebrc
+
+// crSkip returns 1 when the low byte of word, the byte after a temperature,
+// is the '\r' of a Windows \r\n line ending, else 0. The fast parsers add
+// it when stepping to the next line instead of branching. Every line is
+// checked on its own, so files mixing \n and \r\n endings, such as
+// concatenations, parse like either.
+func crSkip(word uint64) uint64 {
+	return (uint64(byte(word)^'\r') - 1) >> 63
+}
@@ -0,0 +1,83 @@
+package onebrc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const lfInput = "Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\nSt. John's;15.2\n"
+
+func TestCRLFMatchesLF(t *testing.T) {
+	crlf := strings.ReplaceAll(lfInput, "\n", "\r\n")
+	want := reference(t, lfInput)
+	if got := aggregate(t, crlf, 1); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// a final line without any line break
+	if got := aggregate(t, strings.TrimSuffix(crlf, "\r\n"), 1); got != want {
+		t.Errorf("without the last line break: got %q, want %q", got, want)
+	}
+	// the LF run after a CRLF one must not keep skipping a byte
+	if got := aggregate(t, lfInput, 1); got != want {
+		t.Errorf("LF after CRLF: got %q, want %q", got, want)
+	}
+}
+
+// Streams span several blocks here, parsed while the next ones are read.
+func TestCRLFStream(t *testing.T) {
+	lf := strings.Repeat(lfInput, 3*streamChunkSize/len(lfInput))
+	want := reference(t, lf)
+	for _, input := range []string{lf, strings.ReplaceAll(lf, "\n", "\r\n")} {
+		results, err := AggregateReader(context.Background(), strings.NewReader(input), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := format(t, results); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+// A file mixing \n and \r\n, as concatenating files from both kinds of
+// systems gives, aggregates like the LF version whichever ending its first
+// line has, in strict mode too, where no line is malformed.
+func TestMixedLineEndings(t *testing.T) {
+	lf := strings.Repeat(lfInput, 2000)
+	want := reference(t, lf)
+	lines := strings.SplitAfter(lf, "\n")
+	for _, crFirst := range []bool{false, true} {
+		var mixed strings.Builder
+		for i, line := range lines {
+			if (i%3 == 0) == crFirst {
+				line = strings.ReplaceAll(line, "\n", "\r\n")
+			}
+			mixed.WriteString(line)
+		}
+		input := mixed.String()
+		for _, workers := range []int{1, 4} {
+			if got := aggregate(t, input, workers); got != want {
+				t.Errorf("CRLF first %v, %d workers: got %.80q, want %.80q", crFirst, workers, got, want)
+			}
+		}
+		results, err := AggregateReader(context.Background(), strings.NewReader(input), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := format(t, results); got != want {
+			t.Errorf("CRLF first %v, streamed: got %.80q, want %.80q", crFirst, got, want)
+		}
+
+		path := writeInput(t, input)
+		if stdout, stderr, code := runMain(t, "-strict", path); stdout != want || stderr != "" || code != 0 {
+			t.Errorf("CRLF first %v, -strict: exit %d, got %.80q, stderr %q", crFirst, code, stdout, stderr)
+		}
+	}
+
+	// two fractional digits take the other fast path
+	t.Cleanup(func() { setDecimals(1) })
+	setDecimals(2)
+	if got, want := aggregate(t, "A;12.34\r\nA;-1.00\nB;0.05\r\n", 1), "{A=-1.00/5.67/12.34, B=0.05/0.05/0.05}\n"; got != want {
+		t.Errorf("-decimals 2: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package onebrc
+
+import (
+	"bufio"
+	"encoding/csv"
+	"strconv"
+)
+
+// writeCSV writes a header row followed by one row per station in the order
+// of names. encoding/csv quotes names containing commas or quotes.
+func writeCSV(writer *bufio.Writer, names []string, stationData map[string]*StationData) {
+	w := csv.NewWriter(writer)
+	header := []string{"station", "min", "mean", "max", "count"}
+	if trackPercentiles {
+		header = append(header, "median", "p90", "p99")
+	}
+	if printStddev {
+		header = append(header, "stddev")
+	}
+	if newReducer != nil {
+		header = append(header, "value")
+	}
+	w.Write(header)
+
+	row := make([]string, 0, len(header))
+	for _, name := range names {
+		s := stationData[name]
+		row = append(row[:0], name,
+			formatTemp(getFloatValue(s.MinTemp)),
+			formatTemp(average(s)),
+			formatTemp(getFloatValue(s.MaxTemp)),
+			strconv.FormatInt(s.Count, 10))
+		if s.hist != nil {
+			row = append(row,
+				formatTemp(getFloatValue(s.hist.percentile(0.5, s.Count))),
+				formatTemp(getFloatValue(s.hist.percentile(0.9, s.Count))),
+				formatTemp(getFloatValue(s.hist.percentile(0.99, s.Count))))
+		}
+		if printStddev {
+			row = append(row, formatTemp(round(stddev(s))))
+		}
+		if s.Reducer != nil {
+			row = append(row, s.Reducer.Report())
+		}
+		w.Write(row)
+	}
+	w.Flush()
+}
+
+// formatTemp formats a temperature in degrees rounded to outputDecimals
+// places. It never prints -0.0: round floors x+0.05, which is +0 for -0 and
+// for negatives that round to zero, and the reference's Math.round returns
+// a long, so it prints 0.0 for them too.
+func formatTemp(f float64) string {
+	return strconv.FormatFloat(round(f), 'f', outputDecimals, 64)
+}
@@ -0,0 +1,38 @@
+package onebrc
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+// Names with commas and quotes come back intact through encoding/csv, with
+// the values of the text output.
+func TestCSVQuotesNames(t *testing.T) {
+	input := "Comma, City;12.3\n\"Quoted\" Town;-4.5\nPlain;7.0\nComma, City;-1.1\nHalf\"quote;0.0\nMünchen;3.3\n"
+	path := writeInput(t, input)
+	stdout, stderr, code := runMain(t, "-format", "csv", path)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr %q", code, stderr)
+	}
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("output %q isn't CSV: %v", stdout, err)
+	}
+	if strings.Join(rows[0], ",") != "station,min,mean,max,count" {
+		t.Fatalf("header %q", rows[0])
+	}
+
+	stations := make([]string, 0, len(rows)-1)
+	counts := make(map[string]string)
+	for _, row := range rows[1:] {
+		stations = append(stations, row[0]+"="+row[1]+"/"+row[2]+"/"+row[3])
+		counts[row[0]] = row[4]
+	}
+	if got, want := "{"+strings.Join(stations, ", ")+"}\n", reference(t, input); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if counts["Comma, City"] != "2" || counts["\"Quoted\" Town"] != "1" {
+		t.Errorf("counts %v", counts)
+	}
+}
@@ -0,0 +1,114 @@
+package onebrc
+
+import (
+	"math"
+	"math/bits"
+)
+
+// twoDecimals switches the parser to temperatures with two fractional
+// digits, such as 12.34, stored internally in hundredths; see setDecimals.
+var twoDecimals = false
+
+// decimalSeparator separates the integer and fractional digits of input
+// temperatures, '.' or ',' for locales that write 12,3; see -decimal-sep.
+// Both have bit 4 clear unlike the digits, so the 0x10101000 mask of
+// scanNumber finds either. It must differ from the field delimiter. Output
+// always uses '.'.
+var decimalSeparator = byte('.')
+
+// fractional digits results are rounded and printed to, and 10 to that
+// power; see setPrecision
+var (
+	outputDecimals         = 1
+	outputScale    float64 = 10
+)
+
+// setDecimals selects how many fractional digits, 1 or 2, temperatures have
+// and rescales the fixed-point bounds, output and rounding to match.
+func setDecimals(decimals int) {
+	twoDecimals = decimals == 2
+	tempDecimals = decimals
+	tempScale = 1
+	for range decimals {
+		tempScale *= 10
+	}
+	minTemp = MIN_TEMP*(tempScale/10) - (tempScale/10 - 1)
+	maxTemp = MAX_TEMP*(tempScale/10) + (tempScale/10 - 1)
+	setPrecision(decimals)
+}
+
+// setPrecision makes results print with digits fractional digits instead of
+// the input's. Min and max are stored exactly, so extra digits are only
+// zeros for them, but means and standard deviations gain real precision.
+func setPrecision(digits int) {
+	outputDecimals = digits
+	outputScale = math.Pow10(digits)
+}
+
+// scanNumber2 is scanNumber for -?d?d.dd temperatures.
+func scanNumber2(scanner *Scanner) int64 {
+	numberWord := scanner.getLongAt(scanner.pos() + 1)
+	// the separator sits at byte 1 to 3 just like with one decimal
+	decimalSepPos := bits.TrailingZeros64(^numberWord & 0x10101000)
+	// a reading with a single fractional digit, such as 12.3, would put the
+	// line break where the hundredths belong
+	if byte(numberWord>>(decimalSepPos-4)) != decimalSeparator ||
+		!isDigit(byte(numberWord>>(decimalSepPos+12))) {
+		return scanLenient(scanner)
+	}
+	number := convertIntoNumber2(decimalSepPos, int64(numberWord))
+	if extraFields {
+		skipRest(scanner, uint64(decimalSepPos>>3)+4)
+		return number
+	}
+	scanner.add(uint64(decimalSepPos>>3) + 5 + crSkip(numberWord>>(decimalSepPos+20)))
+	return number
+}
+
+// convertIntoNumber2 converts the ascii -?d?d.dd in numberWord into
+// hundredths. It aligns the number the same way as convertIntoNumber, which
+// leaves the digits in the form 0xHH00TTUU00 with an extra hundredths byte
+// on top, and sums them with plain shifts instead of a magic multiply.
+func convertIntoNumber2(decimalSepPos int, numberWord int64) int64 {
+	shift := 28 - decimalSepPos
+	// signed is -1 if negative, 0 otherwise
+	signed := ^(numberWord << 59) >> 63
+	designMask := ^(signed & 0xFF)
+	digits := ((numberWord & designMask) << shift) & 0x0F0F000F0F00
+	absValue := ((digits>>8)&0xF)*1000 + ((digits>>16)&0xF)*100 + ((digits>>32)&0xF)*10 + (digits>>40)&0xF
+	return (absValue ^ signed) - signed
+}
+
+// scanLenient parses the temperatures the fast paths can't: integers such
+// as 12 and fractions without a leading zero such as .5. It returns the
+// value in 1/tempScale units, ignoring digits past tempDecimals and any
+// -extra-fields, and moves scanner to the next line.
+func scanLenient(scanner *Scanner) int64 {
+	pos := scanner.pos() + 1
+	negative := scanner.getByteAt(pos) == '-'
+	if negative {
+		pos++
+	}
+	var value int64
+	for ; isDigit(scanner.getByteAt(pos)); pos++ {
+		value = value*10 + int64(scanner.getByteAt(pos)-'0')
+	}
+	fraction := 0
+	if scanner.getByteAt(pos) == decimalSeparator {
+		for pos++; fraction < tempDecimals && isDigit(scanner.getByteAt(pos)); pos++ {
+			value = value*10 + int64(scanner.getByteAt(pos)-'0')
+			fraction++
+		}
+	}
+	for ; fraction < tempDecimals; fraction++ {
+		value *= 10
+	}
+	for scanner.getByteAt(pos) != '\n' {
+		pos++
+	}
+	scanner.position = pos + 1
+	if negative {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,114 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// one name=min/mean/max entry of text output, with or without the count of
+// -with-count; the name is matched lazily like partialEntry
+var resultEntry = regexp.MustCompile(`(.+?)=(-?[0-9.]+)/(-?[0-9.]+)/(-?[0-9.]+)(?:/[0-9]+)?(?:, |$)`)
+
+// resultTemps is the printed min, mean and max of one station.
+type resultTemps [3]float64
+
+var tempNames = [...]string{"min", "mean", "max"}
+
+// diffFiles compares the text output in pathA and pathB, as written by this
+// tool or the reference, and writes every station that is missing from one
+// of them or whose min, mean or max differ by more than tolerance to w. It
+// returns the number of such stations.
+func diffFiles(w io.Writer, pathA, pathB string, tolerance float64) (int, error) {
+	a, err := readResultFile(pathA)
+	if err != nil {
+		return 0, err
+	}
+	b, err := readResultFile(pathB)
+	if err != nil {
+		return 0, err
+	}
+
+	names := make([]string, 0, max(len(a), len(b)))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	differing := 0
+	for _, name := range names {
+		ta, inA := a[name]
+		tb, inB := b[name]
+		switch {
+		case !inB:
+			fmt.Fprintf(w, "%s: only in %s\n", name, pathA)
+		case !inA:
+			fmt.Fprintf(w, "%s: only in %s\n", name, pathB)
+		default:
+			same := true
+			for i := range ta {
+				// the printed values are compared, so a difference of
+				// exactly tolerance still counts as equal
+				if delta := tb[i] - ta[i]; math.Abs(delta) > tolerance+1e-9 {
+					if same {
+						fmt.Fprintf(w, "%s:", name)
+						same = false
+					}
+					fmt.Fprintf(w, " %s %g vs %g (%+.*f)", tempNames[i], ta[i], tb[i], outputDecimals, delta)
+				}
+			}
+			if same {
+				continue
+			}
+			fmt.Fprintln(w)
+		}
+		differing++
+	}
+	return differing, nil
+}
+
+// readResultFile parses {name=min/mean/max, ...} text output back into
+// numbers.
+func readResultFile(path string) (map[string]resultTemps, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %w", path, err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' || data[len(data)-1] != '}' {
+		return nil, fmt.Errorf("failed to parse %s file: not {name=min/mean/max, ...} output", path)
+	}
+	entries := string(data[1 : len(data)-1])
+
+	results := make(map[string]resultTemps, maxNameNum)
+	parsed := 0
+	for _, m := range resultEntry.FindAllStringSubmatch(entries, -1) {
+		parsed += len(m[0])
+		var temps resultTemps
+		for i := range temps {
+			f, err := strconv.ParseFloat(m[2+i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s file: bad entry %q", path, m[0])
+			}
+			temps[i] = f
+		}
+		if _, ok := results[m[1]]; ok {
+			return nil, fmt.Errorf("failed to parse %s file: station %q listed twice", path, m[1])
+		}
+		results[m[1]] = temps
+	}
+	if parsed != len(entries) {
+		return nil, fmt.Errorf("failed to parse %s file: entries must be name=min/mean/max", path)
+	}
+	return results, nil
+}
@@ -0,0 +1,47 @@
+package onebrc
+
+import (
+	"strings"
+	"testing"
+)
+
+// -diff reports a changed mean, a station only in either file and the
+// count of differing stations, and ignores changes within the tolerance.
+func TestDiffReportsKnownChange(t *testing.T) {
+	a := writeInput(t, "{Abha=-3.1/18.0/41.2, Bulawayo=1.0/8.9/20.3, Hamburg=-4.0/12.0/30.1}\n")
+	b := writeInput(t, "{Abha=-3.1/18.3/41.2, Cracow=0.0/9.1/22.4, Hamburg=-4.0/12.0/30.2/17}\n")
+
+	stdout, stderr, code := runMain(t, "-diff", a, b)
+	if code != 1 || stdout != "" {
+		t.Fatalf("exit %d, stdout %q; want 1 and no output", code, stdout)
+	}
+	want := "Abha: mean 18 vs 18.3 (+0.3)\n" +
+		"Bulawayo: only in " + a + "\n" +
+		"Cracow: only in " + b + "\n" +
+		"Hamburg: max 30.1 vs 30.2 (+0.1)\n"
+	if !strings.HasPrefix(stderr, want) {
+		t.Errorf("stderr %q, want it to start with %q", stderr, want)
+	}
+	if !strings.Contains(stderr, "4 stations differ") {
+		t.Errorf("stderr %q doesn't count 4 differing stations", stderr)
+	}
+
+	// within the tolerance only the stations missing from one file differ
+	_, stderr, code = runMain(t, "-diff", "-diff-tolerance", "0.3", a, b)
+	if code != 1 || strings.Contains(stderr, "Abha") || strings.Contains(stderr, "Hamburg") || !strings.Contains(stderr, "2 stations differ") {
+		t.Errorf("-diff-tolerance 0.3: exit %d, stderr %q", code, stderr)
+	}
+
+	if _, stderr, code = runMain(t, "-diff", a, a); code != 0 || stderr != "" {
+		t.Errorf("same file: exit %d, stderr %q; want 0 and nothing logged", code, stderr)
+	}
+}
+
+// -quiet leaves only the exit status of -diff.
+func TestDiffQuiet(t *testing.T) {
+	a := writeInput(t, "{Abha=-3.1/18.0/41.2}\n")
+	b := writeInput(t, "{Abha=-3.1/18.3/41.2}\n")
+	if stdout, stderr, code := runMain(t, "-quiet", "-diff", a, b); code != 1 || stdout != "" || stderr != "" {
+		t.Errorf("exit %d, stdout %q, stderr %q; want 1 and no output", code, stdout, stderr)
+	}
+}
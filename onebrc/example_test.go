@@ -0,0 +1,28 @@
+package onebrc_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nbukhari/1brc/onebrc"
+)
+
+func ExampleAggregate() {
+	path := filepath.Join(os.TempDir(), "example-measurements.txt")
+	if err := os.WriteFile(path, []byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	results, err := onebrc.Aggregate(context.Background(), path, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// temperatures are in tenths of a degree
+	s := results["Hamburg"]
+	fmt.Println(len(results), s.Count, s.MinTemp, s.MaxTemp, s.Sum)
+	// Output: 2 2 -34 120 86
+}
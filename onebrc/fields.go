@@ -0,0 +1,12 @@
+package onebrc
+
+// extraFields makes the parsers skip whatever follows the temperature up to
+// the end of the line, such as the epoch of station;temp;epoch records.
+// Without it the byte after the temperature is taken to end the line.
+var extraFields = false
+
+// skipRest moves scanner from the byte after a temperature, n bytes past
+// the current position, to the start of the next line.
+func skipRest(scanner *Scanner, n uint64) {
+	scanner.position = nextNewLine(scanner, scanner.pos()+n) + 1
+}
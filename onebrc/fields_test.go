@@ -0,0 +1,38 @@
+package onebrc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExtraFields(t *testing.T) {
+	var plain, extra strings.Builder
+	for i := range 500 {
+		line := []string{"Hamburg;12.0", "Bulawayo;-8.9", "Palembang;38.8", "Bulawayo;7", strings.Repeat("Kyoto", 4) + ";-0.5"}[i%5]
+		plain.WriteString(line + "\n")
+		extra.WriteString(line + ";" + []string{"1700000000", "", "17;x"}[i%3] + "\n")
+	}
+	want := reference(t, strings.ReplaceAll(plain.String(), ";7\n", ";7.0\n"))
+
+	setFlag(t, &extraFields, true)
+	for _, size := range []int64{stealChunkSize, 100} {
+		setFlag(t, &chunkSize, size)
+		for _, workers := range []int{1, 4} {
+			if got := aggregate(t, extra.String(), workers); got != want {
+				t.Errorf("chunk size %d, %d workers: got %q, want %q", size, workers, got, want)
+			}
+		}
+	}
+	results, err := AggregateReader(context.Background(), strings.NewReader(extra.String()), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := format(t, results); got != want {
+		t.Errorf("streamed: got %q, want %q", got, want)
+	}
+	// lines without the extra field still parse
+	if got := aggregate(t, plain.String(), 4); got != want {
+		t.Errorf("two-field lines: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package onebrc
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Output filters, see -only and -match. When both are set a station has to
+// pass both: it must be listed and its name must match.
+var (
+	// stations to print, nil for all
+	onlyStations []string
+	// pattern station names must match, nil for all
+	matchStations *regexp.Regexp
+	// print only this many stations with the most measurements, 0 for all
+	topStations = 0
+	// print stations in the order of their first record instead of by name
+	firstSeenOrder = false
+)
+
+// inputBase is the position of the input being parsed among all inputs read
+// so far, so StationData.firstSeen keeps increasing from one input to the
+// next.
+var inputBase uint64
+
+// parseStationList splits a -only value into names, dropping empty ones.
+func parseStationList(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectNames returns the names of the stations to print. It runs after all
+// inputs are merged so filtering never affects the aggregates themselves.
+func selectNames(stationData map[string]*StationData) []string {
+	if onlyStations == nil {
+		names := make([]string, 0, len(stationData))
+		for name := range stationData {
+			if matchStations == nil || matchStations.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	names := make([]string, 0, len(onlyStations))
+	seen := make(map[string]bool, len(onlyStations))
+	for _, name := range onlyStations {
+		// stations are stored under their folded names, see -ignore-case
+		if ignoreCase {
+			name = string(lowerFolded([]byte(name)))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := stationData[name]; !ok {
+			log.Printf("-only: station %q not found", name)
+			continue
+		}
+		if matchStations == nil || matchStations.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sortNames orders names alphabetically, by first record with -order
+// firstseen, or with -top by descending count, ties alphabetically, keeping
+// only the first topStations. -top takes precedence over -order.
+func sortNames(names []string, stationData map[string]*StationData) []string {
+	if topStations == 0 && firstSeenOrder {
+		sort.Slice(names, func(i, j int) bool {
+			return stationData[names[i]].firstSeen < stationData[names[j]].firstSeen
+		})
+		return names
+	}
+	if topStations == 0 {
+		radixSortStrings(names)
+		return names
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := stationData[names[i]].Count, stationData[names[j]].Count
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+	return names[:min(topStations, len(names))]
+}
@@ -0,0 +1,44 @@
+package onebrc
+
+// ignoreCase merges station names that differ only in ASCII letter case,
+// such as "Paris" and "PARIS", and prints them lowercased; see -ignore-case.
+// Non-ASCII letters are compared as is.
+var ignoreCase = false
+
+// hashFolded returns the FNV-1a hash of name with ASCII letters lowercased.
+func hashFolded(name []byte) uint64 {
+	h := fnv1aOffset64
+	for _, c := range name {
+		h = (h ^ uint64(lowerASCII(c))) * fnv1aPrime64
+	}
+	return h
+}
+
+// equalFolded reports whether a and b are equal ignoring ASCII letter case.
+func equalFolded(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lowerFolded returns a copy of name with ASCII letters lowercased.
+func lowerFolded(name []byte) []byte {
+	lower := make([]byte, len(name))
+	for i, c := range name {
+		lower[i] = lowerASCII(c)
+	}
+	return lower
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
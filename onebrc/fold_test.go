@@ -0,0 +1,40 @@
+package onebrc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnoreCase(t *testing.T) {
+	long := "Ciudad de México Norte"
+	input := "Paris;1.0\nPARIS;3.0\npArIs;5.0\nMÉXICO;2.0\nMéxico;4.0\n" +
+		long + ";1.0\nCIUDAD DE MéXICO NORTE;3.0\n"
+	// É isn't ASCII, so MÉXICO and méxico stay apart
+	want := "{ciudad de méxico norte=1.0/2.0/3.0, mÉxico=2.0/2.0/2.0, méxico=4.0/4.0/4.0, paris=1.0/3.0/5.0}\n"
+
+	if got := aggregate(t, input, 1); strings.Count(got, "=") != 7 {
+		t.Errorf("without -ignore-case: got %q, want 7 stations", got)
+	}
+	setFlag(t, &ignoreCase, true)
+	for _, workers := range []int{1, 4} {
+		if got := aggregate(t, input, workers); got != want {
+			t.Errorf("%d workers: got %q, want %q", workers, got, want)
+		}
+	}
+
+	path := writeInput(t, input)
+	if stdout, stderr, code := runMain(t, "-ignore-case", path); stdout != want || code != 0 {
+		t.Errorf("-ignore-case: exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+}
+
+// -only names match the stations they fold to, however they are cased.
+func TestIgnoreCaseOnly(t *testing.T) {
+	path := writeInput(t, "Paris;1.0\nPARIS;3.0\nBerlin;2.0\n")
+	want := "{paris=1.0/2.0/3.0}\n"
+	for _, only := range []string{"Paris", "PARIS", "paris,Paris"} {
+		if stdout, stderr, code := runMain(t, "-ignore-case", "-only", only, path); stdout != want || code != 0 {
+			t.Errorf("-only %s: exit %d, got %q, want %q, stderr %q", only, code, stdout, want, stderr)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package onebrc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// -generate writes the same well-formed lines for the same seed, to stdout
+// or -o, and a shorter run is a prefix of a longer one.
+func TestGenerateIsDeterministic(t *testing.T) {
+	first, stderr, code := runMain(t, "-generate", "5000", "-seed", "7")
+	if code != 0 {
+		t.Fatalf("exit %d, stderr %q", code, stderr)
+	}
+	if !isWellFormed(first) || strings.Count(first, "\n") != 5000 {
+		t.Fatalf("output %.200q... isn't 5000 measurement lines", first)
+	}
+	if again, _, _ := runMain(t, "-generate", "5000", "-seed", "7"); again != first {
+		t.Error("a second run with the same seed differs")
+	}
+	if shorter, _, _ := runMain(t, "-generate", "100", "-seed", "7"); !strings.HasPrefix(first, shorter) {
+		t.Error("-generate 100 isn't a prefix of -generate 5000")
+	}
+	if other, _, _ := runMain(t, "-generate", "5000", "-seed", "8"); other == first {
+		t.Error("seeds 7 and 8 give the same lines")
+	}
+
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	if _, stderr, code := runMain(t, "-generate", "5000", "-seed", "7", "-o", path); code != 0 {
+		t.Fatalf("-o: exit %d, stderr %q", code, stderr)
+	}
+	if written, err := os.ReadFile(path); err != nil || string(written) != first {
+		t.Errorf("-o wrote %.200q..., %v; want the stdout lines", written, err)
+	}
+
+	// pinned, so a change to the station list or the PRNG use shows up
+	if head, _, _ := runMain(t, "-generate", "3"); head != "Montreal;11.0\nPhoenix;14.0\nParakou;23.1\n" {
+		t.Errorf("-generate 3 with the default seed gave %q", head)
+	}
+}
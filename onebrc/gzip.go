@@ -0,0 +1,34 @@
+package onebrc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// createGzipWorkers decompresses r and feeds it to the streaming parser.
+// A gzip stream can neither be mapped nor split at arbitrary offsets, so
+// decompression runs on a single goroutine and bounds throughput well below
+// the mmap path; only parsing of the decompressed blocks is spread across
+// numParsers workers. Expect several times the wall time of the same data
+// uncompressed.
+func createGzipWorkers(ctx context.Context, r io.Reader, numParsers int, finalResult map[string]*StationData, report *strictReport) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	return createStreamWorkers(ctx, zr, numParsers, finalResult, report)
+}
+
+// hasGzipMagic reports whether file starts with the gzip header. It reads
+// with ReadAt so the file offset is left untouched.
+func hasGzipMagic(file *os.File) bool {
+	magic := make([]byte, len(gzipMagic))
+	n, _ := file.ReadAt(magic, 0)
+	return n == len(magic) && bytes.Equal(magic, gzipMagic)
+}
@@ -0,0 +1,42 @@
+package onebrc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A gzipped input gives the output of the plain file, whether it is found
+// by its .gz suffix, by its magic bytes or read from stdin.
+func TestGzipInput(t *testing.T) {
+	data := sample(t, 200_000)
+	path := writeInput(t, string(data))
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	suffixed, unsuffixed := filepath.Join(dir, "measurements.txt.gz"), filepath.Join(dir, "measurements.bin")
+	for _, p := range []string{suffixed, unsuffixed} {
+		if err := os.WriteFile(p, gz.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, stderr, code := runMain(t, "-workers", "4", path)
+	if code != 0 || want == "" {
+		t.Fatalf("plain file: exit %d, stderr %q", code, stderr)
+	}
+	for _, p := range []string{suffixed, unsuffixed} {
+		if got, stderr, code := runMain(t, "-workers", "4", p); got != want || code != 0 {
+			t.Errorf("%s: exit %d, stderr %q, output differs from the plain file", filepath.Base(p), code, stderr)
+		}
+	}
+	if got, stderr, code := runMainStdin(t, gz.String(), "-workers", "4", "-"); got != want || code != 0 {
+		t.Errorf("stdin: exit %d, stderr %q, output differs from the plain file", code, stderr)
+	}
+}
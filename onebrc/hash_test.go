@@ -0,0 +1,195 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/nbukhari/1brc/internal/fasthash"
+)
+
+// foldHash returns the hash hashName computes for name.
+func foldHash(name string) uint64 {
+	data := []byte(name + ";0.0\n" + strings.Repeat("\x00", streamPadding))
+	scanner := &Scanner{pointer: unsafe.Pointer(&data[0]), end: uint64(len(name) + 5)}
+	word, wordB := scanner.getLong(), scanner.getLongAt(8)
+	hash, _ := hashName(word, findDelimiter(word), wordB, findDelimiter(wordB), scanner)
+	return hash
+}
+
+// The fold hash XORs the words of a name, so names whose first two words
+// are swapped collide; a constant hash makes every name collide.
+func TestHashCollisionsKeptApart(t *testing.T) {
+	input := "aaaaaaaabbbbbbbb-x;10.0\nbbbbbbbbaaaaaaaa-x;-10.0\n" +
+		"aaaaaaaabbbbbbbb-x;20.0\nHamburg;1.0\nBulawayo;2.0\n"
+	if foldHash("aaaaaaaabbbbbbbb-x") != foldHash("bbbbbbbbaaaaaaaa-x") {
+		t.Fatal("the swapped names don't collide under the fold hash")
+	}
+	want := reference(t, input)
+	if !strings.Contains(want, "aaaaaaaabbbbbbbb-x=10.0/15.0/20.0") {
+		t.Fatalf("unexpected reference output %q", want)
+	}
+
+	for _, constant := range []bool{false, true} {
+		if constant {
+			setFlag(t, &nameHash, func([]byte) uint64 { return 42 })
+		}
+		for _, shared := range []bool{false, true} {
+			setFlag(t, &useSharedMap, shared)
+			if got := aggregate(t, input, 1); got != want {
+				t.Errorf("constant hash %v, shared map %v: got %q, want %q", constant, shared, got, want)
+			}
+		}
+	}
+}
+
+// Clustering is only reported with -hashstats; default runs keep stderr
+// empty for harnesses that diff it.
+func TestClusteringOnlyLoggedWithHashStats(t *testing.T) {
+	var input strings.Builder
+	for i := range 10000 {
+		fmt.Fprintf(&input, "st%06d;%d.0\n", i, i%100)
+	}
+	path := writeInput(t, input.String())
+	if _, stderr, code := runMain(t, path); code != 0 || stderr != "" {
+		t.Errorf("default run: exit %d, stderr %q", code, stderr)
+	}
+	if _, stderr, _ := runMain(t, "-hashstats", path); !strings.Contains(stderr, "warning: station hashes cluster") {
+		t.Errorf("-hashstats logged %q, want the clustering warning", stderr)
+	}
+}
+
+// Multibyte names go through both hashName paths: México and 東京 fit the
+// 16-byte fast path, the others don't, and some differ only past byte 16.
+func TestUTF8Names(t *testing.T) {
+	names := []string{
+		"México", "東京", "Kraków", "São Paulo",
+		"Ciudad de México", "Санкт-Петербург", "東京都千代田区丸の内",
+		"東京都千代田区丸の外", "Санкт-Петербург-Главный",
+	}
+	var input strings.Builder
+	for i := range 3000 {
+		fmt.Fprintf(&input, "%s;%d.%d\n", names[i%len(names)], i%199-99, i%10)
+	}
+	want := reference(t, input.String())
+	for _, name := range names {
+		if !strings.Contains(want, name+"=") {
+			t.Fatalf("reference output lacks %q: %q", name, want)
+		}
+	}
+
+	setFlag(t, &chunkSize, 4096)
+	for _, workers := range []int{1, 4} {
+		if got := aggregate(t, input.String(), workers); got != want {
+			t.Errorf("%d workers: got %q, want %q", workers, got, want)
+		}
+	}
+}
+
+// XXH64 with a zero seed, the vectors published with the reference
+// implementation and names of every length class: under 4, 8 and 32
+// bytes, and past 32.
+func TestXXHash64(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xEF46DB3751D8E999},
+		{"a", 0xD24EC4F1A98C6E5B},
+		{"abc", 0x44BC2CF5AD770999},
+		{"asdf", 0x415872F599CEA71E},
+		{"Call me Ishmael. Some years ago--never mind how long precisely-", 0x02A2E85470D6FD96},
+		{"Hamburg", 0x4A1C1F1F4030E1A2},
+		{"Bulawayo", 0x270E06B65BBC2696},
+		{"St. John's", 0x5AC3AD768D95714F},
+		{"Petropavlovsk-Kamchatsky", 0x8239B5EAC133C953},
+		{"Las Palmas de Gran Canaria", 0xEA43E08CB6AB3B9F},
+	} {
+		if got := xxhash64([]byte(tc.in)); got != tc.want {
+			t.Errorf("xxhash64(%q) = %#016x, want %#016x", tc.in, got, tc.want)
+		}
+	}
+}
+
+// hashSpread returns how many distinct hashes the names have under
+// strategy, how many of the 65536 slots of a worker map they fill and the
+// most names in one slot.
+func hashSpread(t *testing.T, strategy string, names []string) (hashes, slots, most int) {
+	t.Helper()
+	setFlag(t, &nameHash, nil)
+	if err := setHashStrategy(strategy); err != nil {
+		t.Fatal(err)
+	}
+	size := uint64(newStationMap(maxNameNum).Slots())
+	seen := make(map[uint64]bool)
+	perSlot := make(map[uint64]int)
+	for _, name := range names {
+		hash := foldHash(name)
+		if nameHash != nil {
+			hash = nameHash([]byte(name))
+		}
+		seen[hash] = true
+		slot := fasthash.HashToIndex(hash, size)
+		perSlot[slot]++
+		most = max(most, perSlot[slot])
+	}
+	return len(seen), len(perSlot), most
+}
+
+// The spread quoted at nameHash: the -generate stations and names of the
+// form st012345, under every strategy.
+func TestHashSpread(t *testing.T) {
+	var stations []string
+	for _, s := range generatorStations {
+		stations = append(stations, s.name)
+	}
+	synthetic := make([]string, 10000)
+	for i := range synthetic {
+		synthetic[i] = fmt.Sprintf("st%06d", i)
+	}
+	for _, tc := range []struct {
+		strategy            string
+		names               []string
+		hashes, slots, most int
+	}{
+		{"fold", stations, 413, 408, 2},
+		{"fnv", stations, 413, 413, 1},
+		{"xxhash", stations, 413, 412, 2},
+		{"fold", synthetic, 10000, 100, 100},
+		{"fnv", synthetic, 10000, 9282, 4},
+		{"xxhash", synthetic, 10000, 9284, 4},
+	} {
+		hashes, slots, most := hashSpread(t, tc.strategy, tc.names)
+		if hashes != tc.hashes || slots != tc.slots || most != tc.most {
+			t.Errorf("%s over %d names: %d hashes in %d slots, at most %d per slot; want %d in %d, at most %d",
+				tc.strategy, len(tc.names), hashes, slots, most, tc.hashes, tc.slots, tc.most)
+		}
+	}
+}
+
+// BenchmarkNameHash parses 1M rows with every strategy, over the 413
+// -generate stations and over 95000 names of the form st012345.
+func BenchmarkNameHash(b *testing.B) {
+	var synthetic bytes.Buffer
+	for i := range 1_000_000 {
+		fmt.Fprintf(&synthetic, "st%06d;%d.%d\n", i*7919%95000, i%199-99, i%10)
+	}
+	for _, input := range []struct {
+		name string
+		data []byte
+	}{{"stations", sample(b, 1_000_000)}, {"synthetic", synthetic.Bytes()}} {
+		for _, strategy := range []string{"fold", "fnv", "xxhash"} {
+			b.Run(input.name+"/"+strategy, func(b *testing.B) {
+				setFlag(b, &nameHash, nil)
+				if err := setHashStrategy(strategy); err != nil {
+					b.Fatal(err)
+				}
+				for range b.N {
+					AggregateBytes(input.data, 1)
+				}
+			})
+		}
+	}
+}
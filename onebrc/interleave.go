@@ -0,0 +1,78 @@
+package onebrc
+
+import "unsafe"
+
+// subScanners is the number of interleaved sub-scanners per chunk, see
+// -sub-scanners. Best of five runs of BenchmarkSubScanners over 5M rows on
+// one core: the hand-unrolled four-way loop took 152ms (32.9M rows/s); the
+// generic loop took 172ms with 1, 169ms with 2, 170ms with 3 and 182ms
+// with 8. Anything but 4 goes through the generic loop.
+var subScanners = 4
+
+// readInterleaved is readUsingMMAP for any number of sub-scanners, n >= 1.
+func readInterleaved(data []byte, results *stationMap, offset uint64, bytesToRead uint64, maxAvailable uint64, n int) {
+	pointer := unsafe.Pointer(&data[0])
+	scanner := &Scanner{pointer: pointer, position: offset, end: maxAvailable}
+	segmentStart, segmentEnd := segmentBounds(scanner, offset, bytesToRead, maxAvailable)
+	if segmentStart > segmentEnd {
+		return
+	}
+
+	// sub-segment k runs from the line after bounds[k] up to bounds[k+1]
+	dist := (segmentEnd - segmentStart) / uint64(n)
+	// scanners live on the stack unless there are unusually many, a slice
+	// of variable length would be allocated for every chunk
+	var stackScanners [16]Scanner
+	var scanners []Scanner
+	if n <= len(stackScanners) {
+		scanners = stackScanners[:n]
+	} else {
+		scanners = make([]Scanner, n)
+	}
+	position := segmentStart
+	// sum of the start positions, see -verify-coverage
+	var started uint64
+	for k := range scanners {
+		end := segmentEnd
+		if k < n-1 {
+			end = nextNewLine(scanner, segmentStart+dist*uint64(k+1))
+		}
+		scanners[k].Reset(pointer, position, end)
+		started += position
+		position = end + 1
+	}
+
+	for {
+		for k := range scanners {
+			if !scanners[k].hasNext() {
+				goto drain
+			}
+		}
+		for k := range scanners {
+			s := &scanners[k]
+			word := s.getLong()
+			wordB := s.getLongAt(s.pos() + 8)
+			station := findResult(word, findDelimiter(word), wordB, findDelimiter(wordB), s, results)
+			record(station, scanValue(station, s))
+		}
+	}
+
+drain:
+	for k := range scanners {
+		s := &scanners[k]
+		for s.hasNext() {
+			word := s.getLong()
+			wordB := s.getLongAt(s.pos() + 8)
+			station := findResult(word, findDelimiter(word), wordB, findDelimiter(wordB), s, results)
+			record(station, scanValue(station, s))
+		}
+	}
+
+	if verifyCoverage {
+		var consumed uint64
+		for k := range scanners {
+			consumed += scanners[k].pos()
+		}
+		addCoverage(consumed - started)
+	}
+}
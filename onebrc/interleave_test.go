@@ -0,0 +1,58 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// BenchmarkSubScanners sweeps -sub-scanners on one worker. 4 is the
+// hand-unrolled loop, the others the generic one.
+func BenchmarkSubScanners(b *testing.B) {
+	data := sample(b, 5_000_000)
+	rows := float64(bytes.Count(data, []byte{'\n'}))
+	for _, n := range []int{1, 2, 3, 4, 8} {
+		b.Run(fmt.Sprintf("sub-scanners=%d", n), func(b *testing.B) {
+			setFlag(b, &subScanners, n)
+			for range b.N {
+				AggregateBytes(data, 1)
+			}
+			b.ReportMetric(rows*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
+
+// The sub-scanners of a chunk live on the stack, for up to 16 of them.
+func TestReadChunkAllocs(t *testing.T) {
+	data := sample(t, 100_000)
+	const chunk = 64 << 10
+	for _, n := range []int{1, 3, 4, 8, 16} {
+		setFlag(t, &subScanners, n)
+		results := newStationMap(maxNameNum)
+		// the first run, not counted, adds the stations
+		allocs := testing.AllocsPerRun(10, func() {
+			readUsingMMAP(data, results, 0, chunk, chunk+maxLineLen)
+		})
+		if allocs != 0 {
+			t.Errorf("%d sub-scanners: %v allocations per chunk, want 0", n, allocs)
+		}
+	}
+}
+
+func BenchmarkReadChunk(b *testing.B) {
+	data := sample(b, 100_000)
+	const chunk = 64 << 10
+	for _, n := range []int{3, 4, 8} {
+		b.Run(fmt.Sprintf("sub-scanners=%d", n), func(b *testing.B) {
+			setFlag(b, &subScanners, n)
+			results := newStationMap(maxNameNum)
+			readUsingMMAP(data, results, 0, chunk, chunk+maxLineLen)
+			b.ReportAllocs()
+			b.SetBytes(chunk)
+			b.ResetTimer()
+			for range b.N {
+				readUsingMMAP(data, results, 0, chunk, chunk+maxLineLen)
+			}
+		})
+	}
+}
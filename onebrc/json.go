@@ -0,0 +1,61 @@
+package onebrc
+
+import (
+	"bufio"
+	"encoding/json"
+)
+
+// jsonStation is the JSON form of one station. Temperatures are
+// json.Number so they keep exactly tempDecimals places.
+type jsonStation struct {
+	Min    json.Number `json:"min"`
+	Mean   json.Number `json:"mean"`
+	Max    json.Number `json:"max"`
+	Count  int64       `json:"count"`
+	Median json.Number `json:"median,omitempty"`
+	P90    json.Number `json:"p90,omitempty"`
+	P99    json.Number `json:"p99,omitempty"`
+	Stddev json.Number `json:"stddev,omitempty"`
+	Value  string      `json:"value,omitempty"`
+}
+
+func jsonTemp(f float64) json.Number {
+	return json.Number(formatTemp(f))
+}
+
+// writeJSON streams stationData as a single JSON object keyed by station
+// name in the order of names, marshaling one station at a time straight
+// into writer instead of building the whole document in memory.
+func writeJSON(writer *bufio.Writer, names []string, stationData map[string]*StationData) {
+	writer.WriteByte('{')
+	for i, name := range names {
+		s := stationData[name]
+		if i > 0 {
+			writer.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		writer.Write(key)
+		writer.WriteByte(':')
+
+		station := jsonStation{
+			Min:   jsonTemp(getFloatValue(s.MinTemp)),
+			Mean:  jsonTemp(average(s)),
+			Max:   jsonTemp(getFloatValue(s.MaxTemp)),
+			Count: s.Count,
+		}
+		if s.hist != nil {
+			station.Median = jsonTemp(getFloatValue(s.hist.percentile(0.5, s.Count)))
+			station.P90 = jsonTemp(getFloatValue(s.hist.percentile(0.9, s.Count)))
+			station.P99 = jsonTemp(getFloatValue(s.hist.percentile(0.99, s.Count)))
+		}
+		if s.Reducer != nil {
+			station.Value = s.Reducer.Report()
+		}
+		if printStddev {
+			station.Stddev = jsonTemp(round(stddev(s)))
+		}
+		value, _ := json.Marshal(station)
+		writer.Write(value)
+	}
+	writer.WriteString("}\n")
+}
@@ -0,0 +1,56 @@
+package onebrc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// -format json holds the stations of the text output, in the same order,
+// with names that need escaping intact.
+func TestJSONOutput(t *testing.T) {
+	input := "Hamburg;12.0\nBulawayo;8.9\nPalembang;-38.8\nHamburg;-3.4\n\"Quoted\\Town\";1.5\nMünchen;-0.5\nTab\tCity;4.0\n"
+	path := writeInput(t, input)
+	stdout, stderr, code := runMain(t, "-format", "json", path)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr %q", code, stderr)
+	}
+
+	got, counts := decodeJSON(t, stdout)
+	if want := reference(t, input); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if counts["Hamburg"] != 2 || counts["\"Quoted\\Town\""] != 1 {
+		t.Errorf("counts %v", counts)
+	}
+}
+
+// decodeJSON returns -format json output in the text format, decoded token
+// by token to keep the order of the stations, and the counts by name.
+func decodeJSON(t testing.TB, output string) (string, map[string]int64) {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(output))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		t.Fatalf("output %q doesn't start an object: %v", output, err)
+	}
+	var stations []string
+	counts := make(map[string]int64)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := tok.(string)
+		var s jsonStation
+		if err := dec.Decode(&s); err != nil {
+			t.Fatalf("station %q: %v", name, err)
+		}
+		stations = append(stations, fmt.Sprintf("%s=%s/%s/%s", name, s.Min, s.Mean, s.Max))
+		counts[name] = s.Count
+	}
+	if _, err := dec.Token(); err != nil || dec.More() {
+		t.Fatalf("output %q doesn't end after the object: %v", output, err)
+	}
+	return "{" + strings.Join(stations, ", ") + "}\n", counts
+}
@@ -0,0 +1,131 @@
+package onebrc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// -lines prints the total line count of its inputs, mapped, gzipped or on
+// stdin, blank lines and a final line without '\n' included.
+func TestLinesCount(t *testing.T) {
+	data := sample(t, 30_000)
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"\n", 1},
+		{"Hamburg;12.0", 1},
+		{"Hamburg;12.0\n", 1},
+		{"Hamburg;12.0\n\nBulawayo;8.9", 3},
+		{string(data), 30_000},
+		{string(data) + "Hamburg;12.0", 30_001},
+	}
+	for _, tt := range tests {
+		path := writeInput(t, tt.input)
+		want := strconv.Itoa(tt.want) + "\n"
+		for _, workers := range []string{"1", "3"} {
+			if got, stderr, code := runMain(t, "-lines", "-workers", workers, path); got != want || code != 0 {
+				t.Errorf("%.40q, %s workers: got %q, exit %d, stderr %q; want %q", tt.input, workers, got, code, stderr, want)
+			}
+		}
+		if got, _, _ := runMainStdin(t, tt.input, "-lines", "-"); got != want {
+			t.Errorf("%.40q on stdin: got %q, want %q", tt.input, got, want)
+		}
+	}
+
+	// a gzipped copy and several inputs add up
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+	gzPath := t.TempDir() + "/measurements.txt.gz"
+	if err := os.WriteFile(gzPath, gz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, stderr, _ := runMain(t, "-lines", gzPath, writeInput(t, "a;1.0\nb;2.0")); got != "30002\n" {
+		t.Errorf("gzip and plain file: got %q, stderr %q; want 30002", got, stderr)
+	}
+}
+
+// -lines counts the records a run aggregates: without the -skip-header
+// line, and only those starting in the -start and -length window.
+func TestLinesHeaderAndWindow(t *testing.T) {
+	input := "station;temperature\nHamburg;12.0\nBulawayo;8.9\nHamburg;-3.4"
+	path := writeInput(t, input)
+	if got, stderr, _ := runMain(t, "-lines", "-skip-header", path); got != "3\n" {
+		t.Errorf("-skip-header: got %q, stderr %q; want 3", got, stderr)
+	}
+	if got, stderr, _ := runMainStdin(t, input, "-lines", "-skip-header", "-"); got != "3\n" {
+		t.Errorf("-skip-header on stdin: got %q, stderr %q; want 3", got, stderr)
+	}
+
+	// every window split adds up to the whole file, each part counted
+	// like the records aggregated from it
+	data := string(sample(t, 30_000))
+	path = writeInput(t, data)
+	for _, split := range []int{1, 7, len(data) / 3, len(data) - 1} {
+		first, _, _ := runMain(t, "-lines", "-length", strconv.Itoa(split), path)
+		rest, _, _ := runMain(t, "-lines", "-start", strconv.Itoa(split), path)
+		a, _ := strconv.Atoi(strings.TrimSpace(first))
+		b, _ := strconv.Atoi(strings.TrimSpace(rest))
+		if a+b != 30_000 {
+			t.Errorf("split at %d: %d + %d lines, want 30000", split, a, b)
+		}
+		setFlag(t, &windowLength, int64(split))
+		results, err := Aggregate(context.Background(), path, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := totalCount(results); count != a {
+			t.Errorf("split at %d: -lines counted %d, aggregation %d", split, a, count)
+		}
+	}
+	if _, _, code := runMainStdin(t, data, "-lines", "-start", "10", "-"); code == 0 {
+		t.Error("-lines -start on stdin exited with 0")
+	}
+}
+
+// On a block device -lines counts the text up to the first NUL, as
+// aggregation parses it, and supports windows. It needs a loop device, so
+// root and losetup.
+func TestLinesBlockDevice(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("loop devices need root")
+	}
+	input := "station;temperature\nHamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	image := t.TempDir() + "/disk.img"
+	if err := os.WriteFile(image, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(image, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("losetup", "--find", "--show", image).Output()
+	if err != nil {
+		t.Skipf("no loop device: %v", err)
+	}
+	device := strings.TrimSpace(string(out))
+	t.Cleanup(func() { exec.Command("losetup", "--detach", device).Run() })
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "4\n"},
+		{[]string{"-skip-header"}, "3\n"},
+		{[]string{"-start", "21", "-workers", "3"}, "2\n"},
+		{[]string{"-start", "200"}, "0\n"},
+	} {
+		args := append(append([]string{"-lines"}, tc.args...), device)
+		if got, stderr, code := runMain(t, args...); got != tc.want || code != 0 {
+			t.Errorf("%v: got %q, exit %d, stderr %q; want %q", tc.args, got, code, stderr, tc.want)
+		}
+	}
+}
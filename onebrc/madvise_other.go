@@ -0,0 +1,8 @@
+//go:build !linux
+
+package onebrc
+
+// adviseWillNeed is a no-op where the madvise flags differ from Linux.
+func adviseWillNeed(data []byte) {}
+
+func adviseHugePages(data []byte) {}
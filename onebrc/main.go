@@ -0,0 +1,1498 @@
+package onebrc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/nbukhari/1brc/internal/fasthash"
+	"github.com/pkg/profile"
+)
+
+// StationData accumulates the readings of one station. Readings are at
+// most 999 tenths, or 9999 hundredths with -decimals 2, in magnitude, so the
+// int64 sums hold at least 9.2e14 rows (Sum) and 9.2e10 rows (SumSq, in
+// hundredths; 9.2e12 in tenths) per station before they could overflow,
+// however many files or -combine runs are merged. Count is int64 so 32-bit
+// builds aren't limited to 2^31 rows.
+type StationData struct {
+	name                  string
+	MaxTemp, MinTemp, Sum int64
+	SumSq                 int64 // sum of squared tenths, see stddev
+	Count                 int64
+	nameAddress           uint64
+	nameLength            int
+	hist                  *histogram // nil unless trackPercentiles is set
+	firstSeen             uint64     // input position of the first record, see -order
+	Reducer               Reducer    // nil unless one is registered, see RegisterReducer
+}
+
+type Scanner struct {
+	pointer  unsafe.Pointer
+	position uint64
+	end      uint64
+}
+
+func movePointer(pointer unsafe.Pointer, pos uint64) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(pointer) + uintptr(pos))
+}
+
+// Reset points s at position of the data at pointer, up to end, so a
+// Scanner can be reused instead of allocating a new one.
+func (s *Scanner) Reset(pointer unsafe.Pointer, position uint64, end uint64) {
+	s.pointer, s.position, s.end = pointer, position, end
+}
+
+func (s *Scanner) hasNext() bool {
+	return s.position < s.end
+}
+
+func (s *Scanner) pos() uint64 {
+	return s.position
+}
+
+func (s *Scanner) add(delta uint64) {
+	s.position += delta
+}
+
+func (s *Scanner) getLong() uint64 {
+	return *(*uint64)(movePointer(s.pointer, s.position))
+}
+
+func (s *Scanner) getLongAt(pos uint64) uint64 {
+	return *(*uint64)(movePointer(s.pointer, pos))
+}
+
+func (s *Scanner) getByteAt(pos uint64) byte {
+	return *(*byte)(movePointer(s.pointer, pos))
+}
+
+// nameEquals reports whether station's name has the same bytes as the
+// nameLength bytes at nameAddress.
+func (s *Scanner) nameEquals(station *StationData, nameAddress uint64, nameLength int) bool {
+	if station.nameLength != nameLength {
+		return false
+	}
+	if station.nameAddress == nameAddress {
+		return true
+	}
+	if ignoreCase {
+		return equalFolded(s.getBytesAt(station.nameAddress, nameLength), s.getBytesAt(nameAddress, nameLength))
+	}
+	return bytes.Equal(s.getBytesAt(station.nameAddress, nameLength), s.getBytesAt(nameAddress, nameLength))
+}
+
+func (s *Scanner) getBytesAt(pos uint64, length int) []byte {
+	return unsafe.Slice((*byte)(movePointer(s.pointer, pos)), length)
+}
+
+const (
+	MIN_TEMP      = -999
+	MAX_TEMP      = 999
+	maxNameNum    = 10000
+	mb            = 1024 * 1024 // bytes
+	fnv1aOffset64 = uint64(14695981039346656037)
+	fnv1aPrime64  = uint64(1099511628211)
+
+	// default upper bound on the bytes a worker takes from the queue at a
+	// time, see chunkSize. In BenchmarkSkewedSchedule, 64MB with two
+	// neighbouring regions of long names, the slowest worker finished after
+	// 49.8-50.4ms with size/workers parts and 48.6ms with these chunks at 2
+	// workers, 26.3-27.0ms and 25.0-25.2ms at 4, 14.5-15.3ms and 13.2-13.6ms
+	// at 8, replayed from chunk times measured on one core.
+	stealChunkSize = 4 * mb
+	// least input per worker, see aggregateData
+	minWorkerBytes = 1 * mb
+
+	// longest station name of the 1brc spec, in bytes. Only -validate
+	// rejects longer names, everything else parses names of any length.
+	maxNameLen = 100
+
+	// average entries compared per map lookup past which the name hash is
+	// reported as clustering; a well spread hash stays below 1.5
+	maxProbes  = 4
+	maxLineLen = maxNameLen + 8 // len(";-999.9\n"), the longest line of the spec
+)
+
+var (
+	filePath = "measurements.txt"
+	MASK1    = [...]uint64{0xFF, 0xFFFF, 0xFFFFFF, 0xFFFFFFFF, 0xFFFFFFFFFF, 0xFFFFFFFFFFFF, 0xFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF,
+		0xFFFFFFFFFFFFFFFF}
+	MASK2 = [...]uint64{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFFFFFFFFFFFFFFFF}
+	// fixed-point scale and bounds of stored temperatures; see setDecimals
+	tempDecimals       = 1
+	tempScale    int64 = 10
+	minTemp      int64 = MIN_TEMP
+	maxTemp      int64 = MAX_TEMP
+	// field delimiter, and copied into every byte of a word; see setDelimiter
+	delimiter     = byte(';')
+	delimiterMask = uint64(0x3B3B3B3B3B3B3B3B)
+	// append the standard deviation to every printed station
+	printStddev = false
+	// one of text, json or csv, see printResults
+	outputFormat = "text"
+	// Tuning knobs for the mmap path, see -chunk-size and -overlap. Only
+	// page-cached files were measured here. Smaller chunks balance work
+	// better when lines or cores are uneven; on a spinning disk larger chunks
+	// keep each worker's reads sequential, and on tmpfs or a warm page cache
+	// the defaults are a good start. The overlap only matters for -length
+	// windows, whose mapping must reach the end of the record crossing the
+	// window's end; chunks of a whole mapping find the end of their last
+	// line however long it is.
+	chunkSize     int64 = stealChunkSize
+	overlapMargin int64 = maxLineLen
+	// only count measurements per station, skipping min/max/sum
+	countOnly = false
+	// append the measurement count to every station in text output
+	printCount = false
+	// ask for transparent huge pages behind the mapping, see adviseHugePages
+	hugePages = false
+	// prefault the page tables of the mapping, see populateFlags
+	populate = false
+	// log how evenly station hashes spread over the map buckets
+	hashStats = false
+	// bytes of results buffered per write to the output, see printResults
+	outputBufferSize = 256 * 1024
+	// gzip the results, see printResults
+	gzipOutput = false
+	// ask macOS not to keep the scanned file cached, see uncacheFile
+	noCache = false
+	// drop the first line of every input, a header such as
+	// "station;temperature"
+	skipHeader = false
+)
+
+// Main runs the 1brc command: it parses the flags and inputs of os.Args,
+// sets the package options from them, aggregates the inputs and prints the
+// results. Like any command it exits the process on errors.
+func Main() {
+	if inBrowser {
+		exportJS()
+		return
+	}
+
+	// start timer
+	start := time.Now()
+
+	// parse flags and inputs; env vars remain as fallbacks
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	showVersion := flags.Bool("version", false, "print version, Go version and git commit, then exit")
+	shouldProfile := flags.Bool("profile", os.Getenv("PROFILE") == "true", "write a CPU profile to ./profile")
+	shouldPrintTimer := flags.Bool("timer", os.Getenv("TIMER") == "true", "log the elapsed time to stderr")
+	timerFormat := flags.String("timer-format", "text", "`format` of the -timer report: text, or json for one line with elapsed_ns, bytes, rows, rows_per_sec and workers (implies -timer)")
+	numParsers := flags.Int("workers", defaultWorkers(), "number of parser workers, by default GOMAXPROCS capped by the cgroup CPU quota")
+	outBuffer := flags.Int("output-buffer", outputBufferSize, "`bytes` of results buffered per write to the output")
+	noOutput := flags.Bool("no-output", false, "aggregate and merge as usual but write no results, only log the number of stations, to time parsing without formatting")
+	gzipOut := flags.Bool("gzip-out", false, "gzip-compress the results, in any -format")
+	outPath := flags.String("o", "", "write results to `file` instead of stdout")
+	percentiles := flags.Bool("percentiles", false, "print min/median/p90/p99/max instead of min/mean/max")
+	precision := flags.Int("precision", -1, "fractional `digits` to print results with, 0 to 9; default as many as -decimals")
+	decimals := flags.Int("decimals", 1, "fractional `digits` in temperatures: 1, or 2 for values like 12.34")
+	strict := flags.Bool("strict", false, "validate every record, skip malformed ones and list them on stderr")
+	header := flags.Bool("skip-header", false, "ignore the first line of every input, e.g. a station;temperature header")
+	stationLimit := flags.Int("max-stations", maxStations, "abort when an input has more than `N` distinct stations, a sign of a wrong -delim or corrupt data; 0 for no limit")
+	showHashStats := flags.Bool("hashstats", false, "log the station map's bucket fill, longest probe and average lookup length to stderr, warning when the name hash clusters")
+	force := flags.Bool("force", false, "parse inputs even if they look binary")
+	nocache := flags.Bool("nocache", false, "don't keep the scanned file in the page cache (macOS)")
+	prefault := flags.Bool("populate", false, "prefault the whole mapping when mapping it, faster on a warm page cache, slower on a cold one (Linux)")
+	hugepage := flags.Bool("hugepage", false, "back the mapping with transparent huge pages where the kernel supports it (Linux)")
+	numa := flags.Bool("numa", false, "pin workers to NUMA nodes and give each node a contiguous region (Linux; no-op on single-node hosts)")
+	shared := flags.Bool("shared-map", false, "aggregate into one lock-striped map shared by all workers (less memory, slower)")
+	glob := flags.String("glob", "", "also aggregate every file matching `pattern`, e.g. data/part-*.txt")
+	format := flags.String("format", "text", "output `format`: text, json or csv")
+	summary := flags.Bool("global-summary", false, "after the stations, print the coldest and hottest reading and their stations (text format)")
+	stats := flags.Bool("stats", false, "append the standard deviation to every station")
+	delim := flags.String("delim", ";", "single-byte `separator` between station and temperature (\\t for tab)")
+	decimalSep := flags.String("decimal-sep", ".", "`separator` of the fractional digits in temperatures: . or , as in 12,3; must differ from -delim")
+	withCount := flags.Bool("with-count", false, "append the measurement count to every station (text format; json and csv always have it)")
+	only := flags.String("only", "", "print only the comma-separated station `names`")
+	match := flags.String("match", "", "print only stations whose name matches `regexp`; combined with -only both must hold")
+	top := flags.Int("top", 0, "print only the `N` stations with the most measurements, by count")
+	hashStrategy := flags.String("hash", "fold", "station name `hash`: fold (XOR of the name words), fnv or xxhash")
+	showProgress := flags.Bool("progress", false, "print the bytes parsed and estimated rows/sec to stderr every second")
+	validate := flags.Bool("validate", false, "only check that every record is well-formed; exit non-zero listing the first bad lines if not")
+	chunkBytes := flags.Int64("chunk-size", stealChunkSize, "maximum `bytes` a worker parses per chunk of a mapped file")
+	overlap := flags.Int64("overlap", maxLineLen, "`bytes` mapped past a -length window to finish its last record; raise it for lines longer than the spec's")
+	interleave := flags.Int("sub-scanners", subScanners, "`number` of interleaved scanners per chunk")
+	trim := flags.Bool("trim-names", false, "ignore spaces and tabs around station names (rehashes names, fnv unless -hash is set)")
+	reducer := flags.String("reducer", "", "print the `name`d aggregate per station instead of min/mean/max: "+reducerNames())
+	perWorker := flags.Bool("per-worker-stats", false, "log the chunks, rows and wall time of every worker of each mapped file, to spot load imbalance")
+	coverage := flags.Bool("verify-coverage", false, "check that the chunks of each mapped file cover every byte of its lines exactly once, reporting to stderr")
+	extra := flags.Bool("extra-fields", false, "ignore fields after the temperature, e.g. the epoch of station;temp;epoch lines")
+	order := flags.String("order", "name", "station `order`: name, or firstseen for the order of their first record in the input")
+	from := flags.Int64("start", 0, "aggregate only the records starting at or after byte `offset` of each file")
+	length := flags.Int64("length", 0, "aggregate only the records starting within `bytes` of -start, 0 for all")
+	diff := flags.Bool("diff", false, "compare two files of text output, given as arguments, and report the stations that differ to stderr; exits 1 if any do")
+	diffTolerance := flags.Float64("diff-tolerance", 0, "largest difference in `degrees` of min, mean or max that -diff still treats as equal")
+	rounding := flags.String("rounding", "halfup", "`mode` of rounding means: halfup (toward +inf on ties, like the reference), halfeven, trunc or ceil")
+	combine := flags.Bool("combine", false, "merge files of -with-count output from earlier runs instead of measurements")
+	foldCase := flags.Bool("ignore-case", false, "merge station names differing only in ASCII case, printed lowercased")
+	generate := flags.Int64("generate", 0, "write `N` random measurement lines to -o or stdout instead of aggregating, for reproducible benchmark inputs")
+	seed := flags.Uint64("seed", 1, "`seed` of -generate; the same seed and N give the same file everywhere")
+	countLines := flags.Bool("lines", false, "only print the total number of lines of all inputs, like wc -l but counting a final line without newline")
+	serveAddr := flags.String("serve", "", "listen on `addr`, e.g. :8080, and answer POST /aggregate with the JSON results of the request body instead of reading files")
+	counts := flags.Bool("count-only", false, "only count measurements, printing name=count per station")
+	quiet := flags.Bool("quiet", false, "log nothing to stderr, not even errors (only the exit status reports them), and disable -timer and -progress")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] [file ...]\n\n", os.Args[0])
+		fmt.Fprintf(flags.Output(), "Aggregates min/mean/max per station across all files (default %s, - for stdin).\n\n", filePath)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if *quiet {
+		log.SetOutput(io.Discard)
+		*shouldPrintTimer = false
+		*showProgress = false
+	}
+
+	if *showVersion {
+		printVersion(os.Stdout)
+		os.Exit(0)
+	}
+
+	switch *timerFormat {
+	case "text":
+	case "json":
+		*shouldPrintTimer = !*quiet
+	default:
+		log.Fatalf("invalid -timer-format %q: must be text or json", *timerFormat)
+	}
+	if *shouldProfile {
+		defer profile.Start(profile.ProfilePath("./profile")).Stop()
+	}
+
+	paths := flags.Args()
+	if *glob != "" {
+		matches, err := filepath.Glob(*glob)
+		if err != nil {
+			log.Fatal(fmt.Errorf("invalid -glob %q: %w", *glob, err))
+		}
+		if len(matches) == 0 {
+			log.Fatalf("-glob %q matches no files", *glob)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		paths = []string{filePath}
+	}
+
+	if *delim == `\t` {
+		*delim = "\t"
+	}
+	if len(*delim) != 1 || *delim == "\n" {
+		log.Fatalf("invalid -delim %q: must be a single byte other than newline", *delim)
+	}
+	setDelimiter((*delim)[0])
+	if *decimalSep != "." && *decimalSep != "," {
+		log.Fatalf("invalid -decimal-sep %q: must be . or ,", *decimalSep)
+	}
+	if *decimalSep == *delim {
+		log.Fatalf("-decimal-sep and -delim are both %q: a temperature couldn't be told from the next field", *delim)
+	}
+	decimalSeparator = (*decimalSep)[0]
+	trackPercentiles = *percentiles
+	printStddev = *stats
+	printSummary = *summary
+	useSharedMap = *shared
+	strictMode = *strict || *validate
+	validateOnly = *validate
+	numaAware = *numa
+	hugePages = *hugepage
+	populate = *prefault
+	noCache = *nocache
+	forceBinary = *force
+	if *outBuffer < 1 {
+		log.Fatalf("invalid -output-buffer %d: must be positive", *outBuffer)
+	}
+	outputBufferSize = *outBuffer
+	gzipOutput = *gzipOut
+	hashStats = *showHashStats
+	skipHeader = *header
+	if *stationLimit < 0 {
+		log.Fatalf("invalid -max-stations %d: must not be negative", *stationLimit)
+	}
+	maxStations = *stationLimit
+	countOnly = *counts
+	printCount = *withCount
+	if *only != "" {
+		onlyStations = parseStationList(*only)
+	}
+	if *chunkBytes < 1 {
+		log.Fatalf("invalid -chunk-size %d: must be positive", *chunkBytes)
+	}
+	if *overlap < maxLineLen {
+		log.Fatalf("invalid -overlap %d: must be at least %d, the longest line of the 1brc spec", *overlap, maxLineLen)
+	}
+	if *interleave < 1 {
+		log.Fatalf("invalid -sub-scanners %d: must be positive", *interleave)
+	}
+	if *from < 0 || *length < 0 {
+		log.Fatalf("invalid -start %d or -length %d: must not be negative", *from, *length)
+	}
+	windowStart = *from
+	windowLength = *length
+	chunkSize = *chunkBytes
+	overlapMargin = *overlap
+	subScanners = *interleave
+	if err := setHashStrategy(*hashStrategy); err != nil {
+		log.Fatal(err)
+	}
+	if err := setRounding(*rounding); err != nil {
+		log.Fatal(err)
+	}
+	trimNames = *trim
+	extraFields = *extra
+	verifyCoverage = *coverage
+	perWorkerStats = *perWorker
+	ignoreCase = *foldCase
+	if trimNames && nameHash == nil {
+		nameHash = fasthash.HashBytes64
+	}
+	switch *order {
+	case "name":
+	case "firstseen":
+		firstSeenOrder = true
+	default:
+		log.Fatalf("invalid -order %q: must be name or firstseen", *order)
+	}
+	if *top < 0 {
+		log.Fatalf("invalid -top %d: must not be negative", *top)
+	}
+	topStations = *top
+	if *reducer != "" {
+		if *combine || countOnly || *percentiles || *stats {
+			log.Fatal("-reducer can't be combined with -combine, -count-only, -percentiles or -stats")
+		}
+		if err := setReducer(*reducer); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *generate < 0 {
+		log.Fatalf("invalid -generate %d: must not be negative", *generate)
+	}
+	if *match != "" {
+		re, err := regexp.Compile(*match)
+		if err != nil {
+			log.Fatal(fmt.Errorf("invalid -match %q: %w", *match, err))
+		}
+		matchStations = re
+	}
+
+	if *decimals != 1 && *decimals != 2 {
+		log.Fatalf("invalid -decimals %d: must be 1 or 2", *decimals)
+	}
+	if *decimals == 2 && *percentiles {
+		log.Fatal("-percentiles only supports -decimals 1")
+	}
+	setDecimals(*decimals)
+	if *precision > 9 || *precision < -1 {
+		log.Fatalf("invalid -precision %d: must be between 0 and 9", *precision)
+	}
+	if *precision >= 0 {
+		setPrecision(*precision)
+	}
+
+	switch *format {
+	case "text", "json", "csv":
+		outputFormat = *format
+	default:
+		log.Fatalf("invalid -format %q: must be text, json or csv", *format)
+	}
+	if *combine && (*percentiles || *stats || countOnly || *validate) {
+		log.Fatal("-combine can't be combined with -percentiles, -stats, -count-only or -validate")
+	}
+	if countOnly && (*percentiles || *stats || outputFormat != "text") {
+		log.Fatal("-count-only can't be combined with -percentiles, -stats or -format")
+	}
+	if printSummary && (countOnly || outputFormat != "text") {
+		log.Fatal("-global-summary only works with text output, not -count-only or -format")
+	}
+
+	if *diff {
+		if len(flags.Args()) != 2 {
+			log.Fatal("-diff needs exactly two result files")
+		}
+		if *diffTolerance < 0 {
+			log.Fatalf("invalid -diff-tolerance %g: must not be negative", *diffTolerance)
+		}
+		// the log's writer, without its prefixes, so -quiet silences the
+		// report too
+		n, err := diffFiles(log.Writer(), flags.Arg(0), flags.Arg(1), *diffTolerance)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			log.Printf("%d stations differ", n)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *serveAddr != "" {
+		if *combine || countOnly || *validate || *countLines {
+			log.Fatal("-serve can't be combined with -combine, -count-only, -validate or -lines")
+		}
+		log.Fatal(serve(*serveAddr, *numParsers))
+	}
+
+	// open the output before the run so an unwritable path fails fast
+	var out io.Writer = os.Stdout
+	var outFile *os.File
+	if *outPath != "" {
+		f, err := os.OpenFile(*outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			log.Fatal(fmt.Errorf("failed to create %s file: %w", *outPath, err))
+		}
+		outFile = f
+		out = f
+	}
+
+	if *generate > 0 {
+		if err := generateMeasurements(out, *generate, *seed); err != nil {
+			log.Fatal(fmt.Errorf("failed to write measurements: %w", err))
+		}
+		if outFile != nil {
+			if err := outFile.Close(); err != nil {
+				log.Fatal(fmt.Errorf("failed to write %s file: %w", *outPath, err))
+			}
+		}
+		return
+	}
+	if *countLines {
+		var total int64
+		for _, path := range paths {
+			n, err := CountLines(path, *numParsers)
+			if err != nil {
+				log.Fatal(err)
+			}
+			total += n
+		}
+		fmt.Fprintln(out, total)
+		if outFile != nil {
+			if err := outFile.Close(); err != nil {
+				log.Fatal(fmt.Errorf("failed to write %s file: %w", *outPath, err))
+			}
+		}
+		return
+	}
+
+	ctx, stopInterrupt := interruptContext()
+	var finalResult map[string]*StationData
+	var err error
+	if *combine {
+		finalResult, err = combineFiles(paths)
+	} else {
+		// only the parsers feed the meter, so it runs around them alone
+		if *showProgress {
+			progress = newProgressMeter()
+			go progress.run(os.Stderr)
+		}
+		finalResult, err = AggregateFiles(ctx, paths, *numParsers)
+		if progress != nil {
+			if err != nil {
+				progress.stop()
+			} else {
+				progress.finish(os.Stderr, totalRows(finalResult))
+			}
+			progress = nil
+		}
+	}
+	stopInterrupt()
+	if errors.Is(err, context.Canceled) {
+		log.Print("interrupted, no results printed")
+		os.Exit(130)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if validateOnly {
+		if n := malformedTotal.Load(); n > 0 {
+			log.Fatalf("validation failed: %d malformed records", n)
+		}
+		return
+	}
+	// the merge into finalResult has run either way, only formatting is
+	// skipped; the count goes to the log so stdout stays empty
+	if *noOutput {
+		log.Printf("%d stations", len(finalResult))
+	} else if err := printResults(out, finalResult); err != nil {
+		log.Fatal(fmt.Errorf("failed to write results: %w", err))
+	}
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			log.Fatal(fmt.Errorf("failed to write %s file: %w", *outPath, err))
+		}
+	}
+	if *shouldPrintTimer && *timerFormat == "json" {
+		if err := writeTimerJSON(os.Stderr, time.Since(start), lastRun.bytes, totalRows(finalResult), lastRun.workers); err != nil {
+			log.Fatal(fmt.Errorf("failed to write timings: %w", err))
+		}
+	} else if *shouldPrintTimer {
+		elapsed := time.Since(start)
+		log.Printf("Time took %s, %s read, workers=%d", elapsed, formatBytes(lastRun.bytes), lastRun.workers)
+		// HeapSys never shrinks, so it is the peak heap reserved from the OS
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		log.Printf("Peak heap %s, allocated %s in %d objects, %d GC cycles",
+			formatBytes(mem.HeapSys), formatBytes(mem.TotalAlloc), mem.Mallocs, mem.NumGC)
+	}
+}
+
+// formatBytes renders n with a binary unit, e.g. 1.5 MiB.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// inputSize returns the size of the input open as file, whether it can be
+// mapped, and whether it is a block device. stat reports 0 bytes for a raw
+// disk or partition, so its size is asked from the device; devices whose
+// size is unknown are streamed.
+func inputSize(file *os.File, path string, info os.FileInfo) (size int64, mappable bool, blockDevice bool) {
+	size, mappable = info.Size(), info.Mode().IsRegular()
+	blockDevice = info.Mode()&os.ModeDevice != 0 && info.Mode()&os.ModeCharDevice == 0
+	if blockDevice {
+		if n, err := blockDeviceSize(file); err == nil && n > 0 {
+			size, mappable = n, true
+		} else {
+			log.Printf("%s: can't get the block device size, streaming instead: %v", path, err)
+		}
+	}
+	return size, mappable, blockDevice
+}
+
+// deviceText returns the text at the start of a mapped block device. A
+// partition is larger than the text written to it; what follows the text
+// is taken to be zeroed, so the records end at the first NUL.
+func deviceText(mapped []byte) []byte {
+	if i := bytes.IndexByte(mapped, 0); i >= 0 {
+		return mapped[:i]
+	}
+	return mapped
+}
+
+// createWorkers aggregates the input at path into finalResult. Failures are
+// returned, never fatal, so Main and embedders decide how to handle them.
+// With emit set, the stations of a mapped input are handed to it in name
+// order instead, see AggregateStream; other inputs still go to finalResult.
+func createWorkers(ctx context.Context, path string, numParsers int, finalResult map[string]*StationData, emit func(name string, s *StationData)) (err error) {
+
+	file := os.Stdin
+	if path != "-" {
+		f, err := os.OpenFile(path, os.O_RDONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s file: %w", path, err)
+		}
+		defer f.Close()
+		file = f
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s file: %w", path, err)
+	}
+
+	var report *strictReport
+	if strictMode {
+		report = &strictReport{}
+		defer report.print(path)
+	}
+
+	size, mappable, blockDevice := inputSize(file, path, info)
+
+	windowed := windowStart > 0 || windowLength > 0
+	if windowed && (!mappable || strings.HasSuffix(path, ".gz") || hasGzipMagic(file) || size > math.MaxInt) {
+		return fmt.Errorf("failed to read %s file: -start and -length need an uncompressed file that can be mapped", path)
+	}
+
+	// pipes, sockets and character devices can't be mapped
+	if !mappable {
+		reader := bufio.NewReader(file)
+		magic, _ := reader.Peek(len(gzipMagic))
+		if bytes.Equal(magic, gzipMagic) {
+			err = createGzipWorkers(ctx, reader, numParsers, finalResult, report)
+		} else {
+			err = createStreamWorkers(ctx, reader, numParsers, finalResult, report)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s file: %w", path, err)
+		}
+		return nil
+	}
+
+	// compressed bytes can't be parsed in place
+	if strings.HasSuffix(path, ".gz") || hasGzipMagic(file) {
+		if err := createGzipWorkers(ctx, file, numParsers, finalResult, report); err != nil {
+			return fmt.Errorf("failed to read %s file: %w", path, err)
+		}
+		return nil
+	}
+
+	// nothing to map; Main prints the empty result set
+	if size == 0 {
+		return nil
+	}
+
+	// a mapping is addressed with int, so on 32-bit builds files past 2GB
+	// can't be mapped whole; read them like a stream instead
+	if size > math.MaxInt {
+		log.Printf("%s: %d bytes are too large to map on this platform, streaming instead", path, size)
+		if err := createStreamWorkers(ctx, file, numParsers, finalResult, report); err != nil {
+			return fmt.Errorf("failed to read %s file: %w", path, err)
+		}
+		return nil
+	}
+
+	// map only the window, from the byte before it to find where its first
+	// record starts, up to the overlap past it to finish its last record
+	start, end := windowBounds(size)
+	if start == end {
+		return nil
+	}
+	mapStart := max(start-1, 0) / mapAlignment * mapAlignment
+	mapEnd := size
+	if windowLength > 0 {
+		mapEnd = min(end+overlapMargin, size)
+	}
+
+	mapping, err := mapFile(file, mapStart, mapEnd-mapStart)
+	if err != nil {
+		return fmt.Errorf("failed to mmap %s file: %w", path, err)
+	}
+	defer func() {
+		if closeErr := mapping.close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to unmap %s file: %w", path, closeErr)
+		}
+	}()
+	mapped := mapping.data()
+	if blockDevice {
+		mapped = deviceText(mapped)
+	}
+	data, err := windowRecords(mapped, mapStart, start, end, mapEnd == size)
+	if err != nil {
+		return fmt.Errorf("failed to read %s file: %w", path, err)
+	}
+	if err := checkBinary(data); err != nil {
+		return fmt.Errorf("failed to read %s file: %w", path, err)
+	}
+	if skipHeader && start == 0 {
+		data = cutHeader(data, report)
+	}
+	if report != nil && start > 0 {
+		// data is a slice of mapped, so their capacities differ by its offset
+		report.windowBase = mapStart + int64(cap(mapped)-cap(data))
+	}
+	// madvise needs a page-aligned start, which data past a window or a
+	// header doesn't have, so the hints cover the whole mapping
+	adviseWillNeed(mapped)
+	if hugePages {
+		adviseHugePages(mapped)
+	}
+	if emit != nil {
+		// the names are in the mapping, so they are emitted before it goes
+		stations, tail := parseData(ctx, data, numParsers, report)
+		if checkStations(len(stations) + len(tail)); !stopping(ctx) {
+			emitSorted(data, stations, tail, emit)
+		}
+		return nil
+	}
+	aggregateData(ctx, data, numParsers, finalResult, report)
+	return nil
+}
+
+// queueChunkOffsets sends the offsets of the chunks of [0, size) to the
+// queue of their region, the one of chunkOffsetChs covering that part of
+// [0, size), and closes every queue when done. Each region gets its own
+// sender: a single one filling the queues in offset order would block on
+// the first region's full queue, leaving the workers of the others idle
+// until it was drained.
+func queueChunkOffsets(ctx context.Context, chunkOffsetChs []chan int64, size int64, parseChunkSize int64) {
+	regions := int64(len(chunkOffsetChs))
+	for region, ch := range chunkOffsetChs {
+		go func() {
+			defer close(ch)
+			// the offsets tile [0, size) exactly once; a chunk whose lines
+			// were all taken by the previous one snaps to an empty segment
+			for i := int64(0); i < size && !stopping(ctx); i += parseChunkSize {
+				switch r := i * regions / size; {
+				case r == int64(region):
+					ch <- i
+				case r > int64(region):
+					return
+				}
+			}
+		}()
+	}
+}
+
+// cutHeader returns data without its first line, see -skip-header, and
+// makes report number lines from the one after it.
+func cutHeader(data []byte, report *strictReport) []byte {
+	if report != nil {
+		report.skippedLines = 1
+	}
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return nil
+	}
+	return data[i+1:]
+}
+
+// aggregateData parses data, whole records as read from an input, with
+// numParsers workers and merges the stations into finalResult. Malformed
+// records are added to report in strictMode.
+func aggregateData(ctx context.Context, data []byte, numParsers int, finalResult map[string]*StationData, report *strictReport) {
+	stations, tail := parseData(ctx, data, numParsers, report)
+	for _, s := range stations {
+		mergeStationName(finalResult, stationName(data, s), s)
+	}
+	for _, s := range tail {
+		mergeStation(finalResult, s)
+	}
+}
+
+// parseData parses data like aggregateData but returns what the workers
+// found instead of merging it into a map: every station of the chunks once,
+// with its name still in data, and the stations of the last lines, which
+// are parsed from a copy, keyed by name.
+func parseData(ctx context.Context, data []byte, numParsers int, report *strictReport) ([]*StationData, map[string]*StationData) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	// the lines ending within streamPadding of the end of data, and a final
+	// line without '\n', are parsed from a padded copy, so the word-at-a-time
+	// reads never run past the end of data
+	size := int64(bytes.LastIndexByte(data[:max(len(data)-streamPadding, 0)], '\n') + 1)
+	tail := data[size:]
+
+	// Every worker costs a map to allocate, clear and merge, which outweighs
+	// its share of an input smaller than minWorkerBytes per worker; such
+	// inputs get fewer workers, a tiny file a single one. 16 workers on a
+	// 3-line file allocated 26MB and took 16ms instead of 2.6MB and 3ms.
+	numParsers = int(max(min(int64(numParsers), size/minWorkerBytes), 1))
+	lastRun.workers = max(lastRun.workers, numParsers)
+
+	// Split into chunks of at most chunkSize rather than one per worker,
+	// so a worker that finishes early keeps pulling chunks instead of idling
+	// while another is stuck in a region of unusually long lines. A chunk
+	// is at least one byte, so the offsets below always reach size even
+	// when there are more workers than bytes.
+	parseChunkSize := max(min(size/int64(numParsers), chunkSize), 1)
+
+	// kick off "parser" workers
+	wg := sync.WaitGroup{}
+	wg.Add(numParsers)
+
+	// one queue of chunk offsets per contiguous region of the file; there is
+	// a single region unless workers are placed on NUMA nodes
+	placement := newNumaPlacement(numParsers)
+	chunkOffsetChs := make([]chan int64, placement.regions())
+	for i := range chunkOffsetChs {
+		// buffered to not block on merging
+		chunkOffsetChs[i] = make(chan int64, numParsers)
+	}
+	chunkStatsCh := make(chan *stationMap, numParsers)
+
+	queueChunkOffsets(ctx, chunkOffsetChs, size, parseChunkSize)
+
+	var shared *ConcurrentMap[*StationData]
+	if useSharedMap && !strictMode {
+		shared = NewConcurrentMap[*StationData]()
+	}
+
+	// each worker only writes its own entry
+	var workerStats []workerStat
+	if perWorkerStats {
+		workerStats = make([]workerStat, numParsers)
+	}
+
+	for i := 0; i < numParsers; i++ {
+		node := placement.nodeOf(i, numParsers)
+		chunkOffsetCh := chunkOffsetChs[node]
+		go func() {
+			defer wg.Done()
+			placement.pin(node)
+			started := time.Now()
+			if shared != nil {
+				var slab stationSlab
+				for chunkOffset := range chunkOffsetCh {
+					if ctx.Err() != nil {
+						continue
+					}
+					maxAvailable := min(chunkOffset+parseChunkSize+overlapMargin, size)
+					readShared(data, shared, &slab, uint64(chunkOffset), uint64(parseChunkSize), uint64(maxAvailable))
+					progress.add(data[chunkOffset:min(chunkOffset+parseChunkSize, size)])
+					checkStations(shared.Len())
+					if workerStats != nil {
+						workerStats[i].chunks++
+						workerStats[i].rows += countLines(data[chunkOffset:min(chunkOffset+parseChunkSize, size)])
+					}
+				}
+				if workerStats != nil {
+					workerStats[i].wall = time.Since(started)
+				}
+				return
+			}
+			results := newStationMap(maxNameNum)
+			for chunkOffset := range chunkOffsetCh {
+				// drain the queue without parsing once canceled
+				if ctx.Err() != nil {
+					continue
+				}
+				maxAvailable := min(chunkOffset+parseChunkSize+overlapMargin, size)
+				if report != nil {
+					report.add(readStrict(data, results, uint64(chunkOffset), uint64(parseChunkSize), uint64(maxAvailable)))
+				} else {
+					readUsingMMAP(data, results, uint64(chunkOffset), uint64(parseChunkSize), uint64(maxAvailable))
+				}
+				progress.add(data[chunkOffset:min(chunkOffset+parseChunkSize, size)])
+				checkStations(results.Len())
+				if workerStats != nil {
+					workerStats[i].chunks++
+				}
+			}
+			// the map is merged into others once sent, so count it first
+			if workerStats != nil {
+				workerStats[i].rows = countRows(results)
+				workerStats[i].wall = time.Since(started)
+			}
+			chunkStatsCh <- results
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(chunkStatsCh)
+	}()
+
+	// the worker maps are combined in parallel; the stations of the last one
+	// are handed back with their names not yet copied into strings
+	var stations []*StationData
+	workerMaps := numParsers
+	if shared != nil {
+		workerMaps = 0
+	}
+	if merged := reduceMaps(chunkStatsCh, workerMaps, data); merged != nil {
+		if hashStats {
+			empty, longest, probes := merged.ChainStats()
+			log.Printf("hash map: %d stations in %d slots, %d empty, longest probe %d, %.2f entries compared per lookup",
+				merged.Len(), merged.Slots(), empty, longest, probes)
+			if probes > maxProbes {
+				log.Printf("warning: station hashes cluster, %.1f entries compared per lookup; -hash fnv or xxhash may be faster", probes)
+			}
+		}
+		stations = make([]*StationData, 0, merged.Len())
+		merged.Range(func(_ uint64, s *StationData) bool {
+			stations = append(stations, s)
+			return true
+		})
+	}
+
+	// every station of the shared map is already unique
+	if shared != nil {
+		stations = make([]*StationData, 0, shared.Len())
+		shared.Range(func(s *StationData) {
+			stations = append(stations, s)
+		})
+	}
+	// every worker has handed over its map or finished, so its stats are final
+	if workerStats != nil {
+		reportWorkerStats(workerStats)
+	}
+	// the tail below is copied out and parsed on its own, outside the chunks
+	if verifyCoverage && ctx.Err() == nil {
+		reportCoverage(uint64(size))
+	}
+
+	var tailStations map[string]*StationData
+	if len(tail) > 0 && ctx.Err() == nil {
+		tailStations = make(map[string]*StationData)
+		buf := make([]byte, len(tail)+1+streamPadding)
+		n := copy(buf, tail)
+		if tail[n-1] != '\n' {
+			buf[n] = '\n'
+			n++
+		}
+		bad, malformed := aggregateChunk(buf[:n], uint64(size), newStationMap(1), tailStations)
+		for i := range bad {
+			bad[i].offset += uint64(size)
+		}
+		report.add(bad, malformed)
+		progress.add(tail)
+	}
+	report.numberLines(data)
+	inputBase += uint64(len(data))
+	lastRun.bytes += uint64(len(data))
+	return stations, tailStations
+}
+
+// mergeStation folds s into the entry for s.name in finalResult.
+func mergeStation(finalResult map[string]*StationData, s *StationData) {
+	ms, ok := finalResult[s.name]
+	if !ok {
+		finalResult[s.name] = s
+		return
+	}
+	mergeInto(ms, s)
+}
+
+// mergeStationName is mergeStation for an s whose name is still only name,
+// bytes of the input. The lookup doesn't allocate, so every name is copied
+// into a string once, by the first worker that brings it, not once per worker.
+func mergeStationName(finalResult map[string]*StationData, name []byte, s *StationData) {
+	if ignoreCase {
+		name = lowerFolded(name)
+	}
+	if ms, ok := finalResult[string(name)]; ok {
+		mergeInto(ms, s)
+		return
+	}
+	s.name = string(name)
+	finalResult[s.name] = s
+}
+
+// mergeInto adds the readings of s to ms.
+func mergeInto(ms *StationData, s *StationData) {
+	ms.firstSeen = min(ms.firstSeen, s.firstSeen)
+	if s.MinTemp < ms.MinTemp {
+		ms.MinTemp = s.MinTemp
+	}
+	if s.MaxTemp > ms.MaxTemp {
+		ms.MaxTemp = s.MaxTemp
+	}
+	ms.Sum += s.Sum
+	ms.SumSq += s.SumSq
+	ms.Count += s.Count
+	if ms.hist != nil {
+		ms.hist.merge(s.hist)
+	}
+	if ms.Reducer != nil {
+		ms.Reducer.Merge(s.Reducer)
+	}
+}
+
+func readUsingMMAP(data []byte, results *stationMap, offset uint64, bytesToRead uint64, maxAvailable uint64) {
+	if subScanners != 4 {
+		readInterleaved(data, results, offset, bytesToRead, maxAvailable, subScanners)
+		return
+	}
+	pointer := unsafe.Pointer(&data[0])
+	scanner := &Scanner{pointer: pointer, position: offset, end: maxAvailable}
+	segmentStart, segmentEnd := segmentBounds(scanner, offset, bytesToRead, maxAvailable)
+	if segmentStart > segmentEnd {
+		return
+	}
+
+	dist := (segmentEnd - segmentStart) / 4
+	midPoint1 := nextNewLine(scanner, segmentStart+dist)
+	midPoint2 := nextNewLine(scanner, segmentStart+dist+dist)
+	midPoint3 := nextNewLine(scanner, segmentStart+dist+dist+dist)
+
+	scanner1 := &Scanner{pointer: pointer, position: segmentStart, end: midPoint1}
+	scanner2 := &Scanner{pointer: pointer, position: midPoint1 + 1, end: midPoint2}
+	scanner3 := &Scanner{pointer: pointer, position: midPoint2 + 1, end: midPoint3}
+	scanner4 := &Scanner{pointer: pointer, position: midPoint3 + 1, end: segmentEnd}
+	// sum of the start positions, see -verify-coverage
+	started := scanner1.pos() + scanner2.pos() + scanner3.pos() + scanner4.pos()
+
+	// No software prefetch: the hardware prefetcher follows the four
+	// sequential streams. Over 5M rows on one amd64 core, PREFETCHT0 64 to
+	// 1024 bytes ahead took 108-120ms against 103-108ms without.
+	for {
+		if !scanner1.hasNext() {
+			break
+		}
+		if !scanner2.hasNext() {
+			break
+		}
+		if !scanner3.hasNext() {
+			break
+		}
+		if !scanner4.hasNext() {
+			break
+		}
+		word1 := scanner1.getLong()
+		word2 := scanner2.getLong()
+		word3 := scanner3.getLong()
+		word4 := scanner4.getLong()
+		delimiterMask1 := findDelimiter(word1)
+		delimiterMask2 := findDelimiter(word2)
+		delimiterMask3 := findDelimiter(word3)
+		delimiterMask4 := findDelimiter(word4)
+		word1b := scanner1.getLongAt(scanner1.pos() + 8)
+		word2b := scanner2.getLongAt(scanner2.pos() + 8)
+		word3b := scanner3.getLongAt(scanner3.pos() + 8)
+		word4b := scanner4.getLongAt(scanner4.pos() + 8)
+		delimiterMask1b := findDelimiter(word1b)
+		delimiterMask2b := findDelimiter(word2b)
+		delimiterMask3b := findDelimiter(word3b)
+		delimiterMask4b := findDelimiter(word4b)
+		station1 := findResult(word1, delimiterMask1, word1b, delimiterMask1b, scanner1, results)
+		station2 := findResult(word2, delimiterMask2, word2b, delimiterMask2b, scanner2, results)
+		station3 := findResult(word3, delimiterMask3, word3b, delimiterMask3b, scanner3, results)
+		station4 := findResult(word4, delimiterMask4, word4b, delimiterMask4b, scanner4, results)
+		temp1 := scanValue(station1, scanner1)
+		temp2 := scanValue(station2, scanner2)
+		temp3 := scanValue(station3, scanner3)
+		temp4 := scanValue(station4, scanner4)
+		record(station1, temp1)
+		record(station2, temp2)
+		record(station3, temp3)
+		record(station4, temp4)
+	}
+
+	for scanner1.hasNext() {
+		word := scanner1.getLong()
+		pos := findDelimiter(word)
+		wordB := scanner1.getLongAt(scanner1.pos() + 8)
+		posB := findDelimiter(wordB)
+		station := findResult(word, pos, wordB, posB, scanner1, results)
+		record(station, scanValue(station, scanner1))
+	}
+
+	for scanner2.hasNext() {
+		word := scanner2.getLong()
+		pos := findDelimiter(word)
+		wordB := scanner2.getLongAt(scanner2.pos() + 8)
+		posB := findDelimiter(wordB)
+		station := findResult(word, pos, wordB, posB, scanner2, results)
+		record(station, scanValue(station, scanner2))
+	}
+
+	for scanner3.hasNext() {
+		word := scanner3.getLong()
+		pos := findDelimiter(word)
+		wordB := scanner3.getLongAt(scanner3.pos() + 8)
+		posB := findDelimiter(wordB)
+		station := findResult(word, pos, wordB, posB, scanner3, results)
+		record(station, scanValue(station, scanner3))
+	}
+
+	for scanner4.hasNext() {
+		word := scanner4.getLong()
+		pos := findDelimiter(word)
+		wordB := scanner4.getLongAt(scanner4.pos() + 8)
+		posB := findDelimiter(wordB)
+		station := findResult(word, pos, wordB, posB, scanner4, results)
+		record(station, scanValue(station, scanner4))
+	}
+
+	if verifyCoverage {
+		addCoverage(scanner1.pos() + scanner2.pos() + scanner3.pos() + scanner4.pos() - started)
+	}
+}
+
+// segmentBounds snaps the chunk at offset to whole lines: it starts after
+// the first newline (unless it is the start of the data) and ends on the
+// newline at or after offset+bytesToRead. The segment is empty, with start
+// past end, when no line starts inside the chunk.
+func segmentBounds(scanner *Scanner, offset uint64, bytesToRead uint64, maxAvailable uint64) (uint64, uint64) {
+	segmentEnd := nextNewLine(scanner, min(maxAvailable-1, offset+bytesToRead))
+	var segmentStart uint64
+	if offset == 0 {
+		segmentStart = offset
+	} else {
+		segmentStart = nextNewLine(scanner, offset) + 1
+	}
+	return segmentStart, segmentEnd
+}
+
+// findResult returns the stats of the station named at the scanner position,
+// adding them to stationData on first sight. A line without a delimiter is
+// skipped, as -strict skips it, and gets nil.
+func findResult(initialWord uint64, initialDelimiterMask uint64, wordB uint64, delimiterMaskB uint64, scanner *Scanner,
+	stationData *stationMap) *StationData {
+	var nameAddress = scanner.pos()
+	hash, ok := hashName(initialWord, initialDelimiterMask, wordB, delimiterMaskB, scanner)
+	if !ok {
+		return nil
+	}
+	lineStart := nameAddress
+
+	// Compare names on a hash hit so colliding names are kept apart.
+	nameLength := int(scanner.pos() - nameAddress)
+	if trimNames {
+		nameAddress, nameLength = trimName(scanner, nameAddress, nameLength)
+	}
+	if ignoreCase {
+		hash = hashFolded(scanner.getBytesAt(nameAddress, nameLength))
+	} else if nameHash != nil {
+		hash = nameHash(scanner.getBytesAt(nameAddress, nameLength))
+	}
+	// folded names can't be compared byte for byte by the map
+	if ignoreCase {
+		existingResult, ok := stationData.GetUsingHashFunc(hash, func(s *StationData) bool {
+			return scanner.nameEquals(s, nameAddress, nameLength)
+		})
+		if ok {
+			return existingResult
+		}
+	} else {
+		// the map compares names of up to 16 bytes as their first two
+		// words, zero past the end of the name
+		word1, word2 := initialWord, wordB
+		if trimNames {
+			word1, word2 = scanner.getLongAt(nameAddress), scanner.getLongAt(nameAddress+8)
+		}
+		word1 &= ^uint64(0) >> (64 - 8*min(nameLength, 8))
+		word2 &= ^uint64(0) >> (64 - 8*min(max(nameLength-8, 0), 8))
+		if existingResult, ok := stationData.GetUsingHashAndWords(hash, word1, word2, scanner.getBytesAt(nameAddress, nameLength)); ok {
+			return existingResult
+		}
+	}
+
+	// No stored name holds a '\n', so a name that ran past the end of its
+	// line never matches one and is only caught here, off the hot path.
+	if bytes.IndexByte(scanner.getBytesAt(nameAddress, nameLength), '\n') >= 0 {
+		skipLine(scanner, lineStart)
+		return nil
+	}
+	result := newStation(&stationData.slab, nameAddress, nameLength)
+	stationData.SetUsingHashAndKey(hash, scanner.getBytesAt(nameAddress, nameLength), result)
+	return result
+}
+
+// hashName returns the hash of the station name at the scanner position and
+// advances the scanner to the delimiter that ends it. The search doesn't stop
+// at a '\n', so on a line without a delimiter it runs into the next line,
+// which findResult catches when the name is added; only if it runs off the
+// end of the scanner does hashName give up and return false.
+//
+// Names are treated as raw bytes, which is safe for UTF-8: no byte of a
+// multibyte sequence is below 0x80, so it never equals the delimiter. The
+// fast path (delimiter within 16 bytes) and the slow path fold words
+// differently, but the path only depends on the name's length, so a name
+// always hashes the same wherever it appears.
+func hashName(initialWord uint64, initialDelimiterMask uint64, wordB uint64, delimiterMaskB uint64, scanner *Scanner) (uint64, bool) {
+	word := initialWord
+	delimiterMask := initialDelimiterMask
+	var hash uint64
+	var word2 = wordB
+	var delimiterMask2 = delimiterMaskB
+	if (delimiterMask | delimiterMask2) != 0 {
+		letterCount1 := uint64(bits.TrailingZeros64(delimiterMask) >> 3)  // value between 1 and 8
+		letterCount2 := uint64(bits.TrailingZeros64(delimiterMask2) >> 3) // value between 0 and 8
+		mask := MASK2[letterCount1]
+		word = word & MASK1[letterCount1]
+		word2 = mask & word2 & MASK1[letterCount2]
+		hash = word ^ word2
+		scanner.add(letterCount1 + (letterCount2 & mask))
+	} else {
+		// Slow-path for when the ';' could not be found in the first 16 bytes.
+		hash = word ^ word2
+		scanner.add(16)
+		for {
+			// A line without a ';' would run the search off the end of the
+			// input. Scanners end on a '\n', so the line is the last one.
+			if scanner.pos() >= scanner.end {
+				scanner.position = scanner.end + 1
+				return 0, false
+			}
+			word = scanner.getLong()
+			delimiterMask = findDelimiter(word)
+			if delimiterMask != 0 {
+				trailingZeros := bits.TrailingZeros64(delimiterMask)
+				word = (word << (63 - trailingZeros))
+				scanner.add(uint64(trailingZeros >> 3))
+				hash ^= word
+				break
+			} else {
+				scanner.add(8)
+				hash ^= word
+			}
+		}
+	}
+	return hash, true
+}
+
+// skipLine moves the scanner past the '\n' ending the line at lineStart,
+// for a line without a delimiter.
+func skipLine(scanner *Scanner, lineStart uint64) {
+	scanner.position = nextNewLine(scanner, lineStart) + 1
+}
+
+// newStation returns empty stats from slab for the name at nameAddress.
+func newStation(slab *stationSlab, nameAddress uint64, nameLength int) *StationData {
+	result := slab.alloc()
+	*result = StationData{
+		MinTemp:     maxTemp,
+		MaxTemp:     minTemp,
+		Count:       0,
+		nameAddress: nameAddress,
+		nameLength:  nameLength,
+		firstSeen:   inputBase + nameAddress,
+	}
+	if trackPercentiles {
+		result.hist = new(histogram)
+	}
+	if newReducer != nil {
+		result.Reducer = newReducer()
+	}
+	return result
+}
+
+// findDelimiter marks the delimiter bytes of word with their high bit. It
+// works on words the scan loads anyway for hashing, so a wider SIMD search
+// (NEON on arm64) would need a second load and bring nothing: the name
+// almost always ends within the first 16 bytes.
+func findDelimiter(word uint64) uint64 {
+	input := word ^ delimiterMask
+	return (input - 0x0101010101010101) & ^input & 0x8080808080808080
+}
+
+// setDelimiter switches the byte separating station and temperature.
+func setDelimiter(delim byte) {
+	delimiter = delim
+	delimiterMask = uint64(delim) * 0x0101010101010101
+}
+
+// nextNewLine returns the position of the first '\n' at or after prev. It
+// only runs a few times per chunk to find segment bounds, the hot loop gets
+// line ends from scanNumber, so it is kept portable rather than vectorized.
+func nextNewLine(scanner *Scanner, prev uint64) uint64 {
+	for {
+		currentWord := scanner.getLongAt(prev)
+		input := currentWord ^ 0x0A0A0A0A0A0A0A0A
+		pos := (input - 0x0101010101010101) & ^input & 0x8080808080808080
+		if pos != 0 {
+			prev += (uint64(bits.TrailingZeros64(uint64(pos))) >> 3)
+			break
+		} else {
+			prev += 8
+		}
+	}
+	return prev
+}
+
+func scanNumber(scanner *Scanner) int64 {
+	if twoDecimals {
+		return scanNumber2(scanner)
+	}
+	numberWord := scanner.getLongAt(scanner.pos() + 1)
+	decimalSepPos := bits.TrailingZeros64(^numberWord & 0x10101000)
+	if byte(numberWord>>(decimalSepPos-4)) != decimalSeparator {
+		return scanLenient(scanner)
+	}
+	number := convertIntoNumber(decimalSepPos, int64(numberWord))
+	if extraFields {
+		skipRest(scanner, uint64(decimalSepPos>>3)+3)
+		return number
+	}
+	scanner.add(uint64(decimalSepPos>>3) + 4 + crSkip(numberWord>>(decimalSepPos+12)))
+	return number
+}
+
+// Special method to convert a number in the ascii number into an int without branches created by Quan Anh Mai.
+// TestConvertIntoNumber checks every reading from -99.9 to 99.9 and -0.0.
+// convertIntoNumberArch is the same in amd64 assembly, and pure Go elsewhere.
+// In BenchmarkConvertIntoNumber on one amd64 core the assembly converted the
+// 1999 readings in 6.8-10.5us against 7.5-8.3us for this one, which the
+// compiler inlines, and BenchmarkReadChunk parsed 309-322MB/s with it
+// against 323-343MB/s, so scanNumber keeps calling this one.
+func convertIntoNumber(decimalSepPos int, numberWord int64) int64 {
+	shift := 28 - decimalSepPos
+	// signed is -1 if negative, 0 otherwise
+	signed := ^(numberWord << 59) >> 63
+	designMask := ^(signed & 0xFF)
+	// Align the number to a specific position and transform the ascii to digit value
+	digits := ((numberWord & designMask) << shift) & 0x0F000F0F00
+	// Now digits is in the form 0xUU00TTHH00 (UU: units digit, TT: tens digit, HH: hundreds digit)
+	// 0xUU00TTHH00 * (100 * 0x1000000 + 10 * 0x10000 + 1) =
+	// 0x000000UU00TTHH00 + 0x00UU00TTHH000000 * 10 + 0xUU00TTHH00000000 * 100
+	absValue := ((digits * 0x640a0001) >> 32) & 0x3FF
+	return (absValue ^ signed) - signed
+}
+
+// scanValue is scanNumber for a line findResult returned station for; a nil
+// station was skipped whole and has no value left to scan.
+func scanValue(station *StationData, scanner *Scanner) int64 {
+	if station == nil {
+		return 0
+	}
+	return scanNumber(scanner)
+}
+
+func record(station *StationData, temp int64) {
+	if station == nil {
+		return
+	}
+	if countOnly {
+		station.Count++
+		return
+	}
+	if temp < station.MinTemp {
+		station.MinTemp = temp
+	}
+	if temp > station.MaxTemp {
+		station.MaxTemp = temp
+	}
+	station.Sum += temp
+	station.SumSq += temp * temp
+	station.Count++
+	if station.hist != nil {
+		station.hist.add(temp)
+	}
+	if station.Reducer != nil {
+		station.Reducer.Record(temp)
+	}
+}
+
+func getFloatValue(val int64) float64 {
+	return float64(val) / float64(tempScale)
+}
+
+func printResults(out io.Writer, stationData map[string]*StationData) error { // doesn't help
+	names := sortNames(selectNames(stationData), stationData)
+
+	// the buffer sits in front of the compressor so it sees large writes
+	var zw *gzip.Writer
+	if gzipOutput {
+		zw = gzip.NewWriter(out)
+		out = zw
+	}
+	writer := bufio.NewWriterSize(out, outputBufferSize)
+	switch {
+	case countOnly:
+		writeCounts(writer, names, stationData)
+	case outputFormat == "json":
+		writeJSON(writer, names, stationData)
+	case outputFormat == "csv":
+		writeCSV(writer, names, stationData)
+	default:
+		writeText(writer, names, stationData)
+		if printSummary {
+			writeSummary(writer, names, stationData)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if zw != nil {
+		// Close writes the gzip trailer, without it the stream is truncated
+		return zw.Close()
+	}
+	return nil
+}
+
+// writeText writes the 1BRC {name=min/mean/max, ...} format. Stations go
+// straight to writer one by one, so the output is never held whole, however
+// many stations there are.
+func writeText(writer *bufio.Writer, names []string, stationData map[string]*StationData) {
+	writer.WriteByte('{')
+	for i, name := range names {
+		s := stationData[name]
+		if i > 0 {
+			writer.WriteString(", ")
+		}
+		writer.WriteString(name)
+		writer.WriteByte('=')
+		if s.Reducer != nil {
+			writer.WriteString(s.Reducer.Report())
+		} else if s.hist != nil {
+			writer.WriteString(formatTemp(getFloatValue(s.MinTemp)))
+			for _, p := range [...]float64{0.5, 0.9, 0.99} {
+				writer.WriteByte('/')
+				writer.WriteString(formatTemp(getFloatValue(s.hist.percentile(p, s.Count))))
+			}
+			writer.WriteByte('/')
+			writer.WriteString(formatTemp(getFloatValue(s.MaxTemp)))
+		} else {
+			writer.WriteString(formatTemp(getFloatValue(s.MinTemp)))
+			writer.WriteByte('/')
+			writer.WriteString(formatTemp(average(s)))
+			writer.WriteByte('/')
+			writer.WriteString(formatTemp(getFloatValue(s.MaxTemp)))
+		}
+		if printStddev {
+			writer.WriteByte('/')
+			writer.WriteString(formatTemp(round(stddev(s))))
+		}
+		if printCount {
+			writer.WriteByte('/')
+			writer.WriteString(strconv.FormatInt(s.Count, 10))
+		}
+	}
+	writer.WriteString("}\n")
+}
+
+// writeCounts writes {name=count, ...} for -count-only, streaming like
+// writeText.
+func writeCounts(writer *bufio.Writer, names []string, stationData map[string]*StationData) {
+	writer.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			writer.WriteString(", ")
+		}
+		writer.WriteString(name)
+		writer.WriteByte('=')
+		writer.WriteString(strconv.FormatInt(stationData[name].Count, 10))
+	}
+	writer.WriteString("}\n")
+}
+
+// average returns the mean of s in degrees, rounded to outputDecimals
+// places by -rounding; by default half up, toward positive infinity, like
+// the reference's Math.round. It divides in integers: the float quotient of a tie is often
+// just below it, so e.g. -99.65 used to round to -99.7 instead of -99.6.
+func average(s *StationData) float64 {
+	num, den := s.Sum, s.Count
+	scale := int64(1)
+	for d := tempDecimals; d < outputDecimals; d++ {
+		scale *= 10
+	}
+	for d := outputDecimals; d < tempDecimals; d++ {
+		den *= 10
+	}
+	// sums too large to rescale exactly fall back to the float quotient
+	if num > (math.MaxInt64/2-den)/scale || num < -(math.MaxInt64/2-den)/scale {
+		return roundMean(getFloatValue(s.Sum) / float64(s.Count))
+	}
+	return float64(roundQuotient(num*scale, den)) / outputScale
+}
+
+// stddev returns the population standard deviation of s in degrees.
+// Readings are bounded by |999| tenths, so each SumSq term is below 1e6 and
+// a billion rows stay under 1e15, far from the int64 limit of ~9.2e18. In
+// hundredths the bound is 1e17, still well inside int64.
+func stddev(s *StationData) float64 {
+	mean := getFloatValue(s.Sum) / float64(s.Count)
+	meanSq := float64(s.SumSq) / float64(tempScale*tempScale) / float64(s.Count)
+	return math.Sqrt(max(meanSq-mean*mean, 0))
+}
+
+// rounding floats to outputDecimals places, by default 1 decimal place with
+// 0.05 rounding up to 0.1
+func round(x float64) float64 {
+	return roundTo(x, outputScale)
+}
+
+// roundTo rounds x half up to a multiple of 1/scale.
+func roundTo(x float64, scale float64) float64 {
+	return math.Floor((x+0.5/scale)*scale) / scale
+}
@@ -0,0 +1,636 @@
+package onebrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// TestMain runs Main instead of the tests when runMain starts the test
+// binary, so command line behavior such as exit codes can be tested.
+func TestMain(m *testing.M) {
+	if os.Getenv("ONEBRC_RUN_MAIN") == "1" {
+		Main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the command with args and returns its stdout, stderr and
+// exit code.
+func runMain(t testing.TB, args ...string) (string, string, int) {
+	t.Helper()
+	return runMainStdin(t, "", args...)
+}
+
+// runMainStdin is runMain with stdin read from a string.
+func runMainStdin(t testing.TB, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "ONEBRC_RUN_MAIN=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+// writeInput writes input to a file in a temporary directory and returns
+// its path.
+func writeInput(t testing.TB, input string) string {
+	t.Helper()
+	path := t.TempDir() + "/measurements.txt"
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setFlag sets the option at p, one of the package-level flag variables, to
+// v until t ends.
+func setFlag[T any](t testing.TB, p *T, v T) {
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// aggregate parses input with AggregateBytes and returns what printResults
+// writes for it.
+func aggregate(t testing.TB, input string, workers int) string {
+	t.Helper()
+	return format(t, AggregateBytes([]byte(input), workers))
+}
+
+func format(t testing.TB, results map[string]*StationData) string {
+	t.Helper()
+	var out strings.Builder
+	if err := printResults(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// reference aggregates lines of name;temperature the slow, obvious way. The
+// temperatures must have one decimal.
+func reference(t testing.TB, input string) string {
+	t.Helper()
+	results := make(map[string]*StationData)
+	for _, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndexByte(line, ';')
+		f, err := strconv.ParseFloat(line[i+1:], 64)
+		if i < 0 || err != nil {
+			t.Fatalf("bad reference line %q", line)
+		}
+		name, temp := line[:i], fixedPoint(f)
+		s, ok := results[name]
+		if !ok {
+			s = &StationData{name: name, MinTemp: temp, MaxTemp: temp}
+			results[name] = s
+		}
+		s.MinTemp = min(s.MinTemp, temp)
+		s.MaxTemp = max(s.MaxTemp, temp)
+		s.Sum += temp
+		s.Count++
+	}
+	return format(t, results)
+}
+
+// a line the fast path parses exactly like reference
+var wellFormed = regexp.MustCompile(`^[^;\n\r]+;-?[0-9]{1,2}\.[0-9]$`)
+
+func isWellFormed(input string) bool {
+	if !strings.HasSuffix(input, "\n") {
+		return false
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		if !wellFormed.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// FuzzAggregateBytes checks that no input crashes the parser or reads past
+// it, and that well-formed input aggregates like reference, in one chunk and
+// cut into chunks of a few lines.
+func FuzzAggregateBytes(f *testing.F) {
+	f.Add([]byte("Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"))
+	f.Add([]byte("Hamburg;12.0\nBula"))
+	f.Add([]byte("Hamburg;12.0\nBulawayo\n"))
+	f.Add([]byte("a;1.0\n;\n\n;;\n-;-\n"))
+	f.Add([]byte(strings.Repeat("x", 300) + ";1.5\n" + strings.Repeat("y", 300) + "\n"))
+	f.Add([]byte("México;-0.1\n東京;99.9\nKraków;-99.9\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// AggregateBytes only reads data, a copy catches writes
+		input := string(data)
+		for _, size := range []int64{stealChunkSize, 16} {
+			setFlag(t, &chunkSize, size)
+			got := format(t, AggregateBytes(data, 3))
+			if !bytes.Equal(data, []byte(input)) {
+				t.Fatal("AggregateBytes modified its input")
+			}
+			if isWellFormed(input) {
+				if want := reference(t, input); got != want {
+					t.Fatalf("chunk size %d: got %q, want %q", size, got, want)
+				}
+			}
+		}
+	})
+}
+
+// linesOfSize returns exactly size bytes of measurement lines of varying
+// length, size >= 6, and the number of lines.
+func linesOfSize(size int) (string, int) {
+	var b strings.Builder
+	lines := 0
+	for i := 0; ; i++ {
+		line := fmt.Sprintf("%s;%d.%d\n", strings.Repeat("st", 1+i%7), i%199-99, i%10)
+		if rest := size - b.Len() - len(line); rest < 6 && rest != 0 {
+			break
+		}
+		b.WriteString(line)
+		lines++
+		if b.Len() == size {
+			return b.String(), lines
+		}
+	}
+	// the last line takes what is left, at least "x;1.0\n"
+	b.WriteString(strings.Repeat("x", size-b.Len()-5) + ";1.0\n")
+	return b.String(), lines + 1
+}
+
+func totalCount(results map[string]*StationData) int {
+	rows := 0
+	for _, s := range results {
+		rows += int(s.Count)
+	}
+	return rows
+}
+
+// The chunk offsets must cover the data exactly once whatever its size, the
+// worker count and the chunk size, so every line is counted once.
+func TestEveryLineCountedOnce(t *testing.T) {
+	for _, size := range []int64{7, 64, 1000, stealChunkSize} {
+		setFlag(t, &chunkSize, size)
+		for workers := 1; workers <= 16; workers++ {
+			for n := 6; n < 3000; n += 31 * workers {
+				for _, size := range []int{n, n - 1, n + 1} {
+					if size < 6 {
+						continue
+					}
+					input, lines := linesOfSize(size)
+					if rows := totalCount(AggregateBytes([]byte(input), workers)); rows != lines {
+						t.Fatalf("%d bytes, %d workers, chunk size %d: counted %d rows of %d",
+							size, workers, chunkSize, rows, lines)
+					}
+				}
+			}
+		}
+	}
+
+	// inputs large enough that every worker gets its share
+	for _, workers := range []int{2, 3, 4} {
+		for _, size := range []int{workers*minWorkerBytes - 1, workers * minWorkerBytes, workers*minWorkerBytes + 1} {
+			input, lines := linesOfSize(size)
+			if rows := totalCount(AggregateBytes([]byte(input), workers)); rows != lines {
+				t.Fatalf("%d bytes, %d workers: counted %d rows of %d", size, workers, rows, lines)
+			}
+		}
+	}
+}
+
+// Names past the spec's 100 bytes are parsed, merged and printed whole.
+func TestLongNameRoundTrip(t *testing.T) {
+	long := strings.Repeat("Llanfair", 31) + "xy"  // 250 bytes
+	longUTF8 := strings.Repeat("東京", 41) + "Kyoto" // 251 bytes
+	var input strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&input, "%s;%d.%d\nHamburg;1.0\n%s;-%d.%d\n", long, i%100, i%10, longUTF8, i%100, i%7)
+	}
+	want := reference(t, input.String())
+	if !strings.Contains(want, ", "+long+"=") || !strings.Contains(want, ", "+longUTF8+"=") {
+		t.Fatalf("unexpected reference output %.100q", want)
+	}
+
+	for _, size := range []int64{stealChunkSize, 100} {
+		setFlag(t, &chunkSize, size)
+		for _, workers := range []int{1, 4} {
+			if got := aggregate(t, input.String(), workers); got != want {
+				t.Errorf("chunk size %d, %d workers: got %q, want %q", size, workers, got, want)
+			}
+		}
+	}
+	results, err := AggregateReader(context.Background(), strings.NewReader(input.String()), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := format(t, results); got != want {
+		t.Errorf("streamed: got %q, want %q", got, want)
+	}
+}
+
+// A line without a delimiter is skipped, wherever it is and however long,
+// and never adds a station; -strict reports it.
+func TestFinalRecordWithoutDelimiter(t *testing.T) {
+	const valid = "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	want := reference(t, valid)
+	long := strings.Repeat("x", 90)
+	inputs := []string{
+		valid + "Bula", valid + strings.Repeat("x", 26), valid + long,
+		valid + "Bula\n", valid + long + "\n",
+		"nodelim\n" + valid, long + "\n" + valid,
+		"Hamburg;12.0\nBula\nBulawayo;8.9\n" + long + "\nHamburg;-3.4\n",
+	}
+	for _, input := range inputs {
+		for _, workers := range []int{1, 3} {
+			for _, shared := range []bool{false, true} {
+				setFlag(t, &useSharedMap, shared)
+				if got := aggregate(t, input, workers); got != want {
+					t.Errorf("%q, %d workers, shared %v: got %q, want %q", input, workers, shared, got, want)
+				}
+			}
+		}
+		if got, stderr, _ := runMainStdin(t, input, "-workers", "3", "-"); got != want {
+			t.Errorf("%q streamed: got %q, stderr %q, want %q", input, got, stderr, want)
+		}
+
+		path := writeInput(t, input)
+		for _, args := range [][]string{{"-strict", path}, {"-strict", "-"}} {
+			stdout, stderr, code := runMainStdin(t, input, args...)
+			if code != 0 || stdout != want || !strings.Contains(stderr, ": missing delimiter") {
+				t.Errorf("%q, %v: exit %d, stdout %q, stderr %q, want %q", input, args, code, stdout, stderr, want)
+			}
+		}
+	}
+}
+
+// findDelimiter may also mark bytes after the first delimiter, but callers
+// only use the lowest marked byte, so that is what is compared.
+func TestFindDelimiterRandom(t *testing.T) {
+	t.Cleanup(func() { setDelimiter(';') })
+	r := rand.New(rand.NewPCG(1, 2))
+	var word [8]byte
+	for _, delim := range []byte{';', ',', '\t', '|', 0x80} {
+		setDelimiter(delim)
+		for range 200000 {
+			for i := range word {
+				// mostly bytes next to the delimiter, so borrows
+				// between bytes are exercised
+				if r.IntN(4) == 0 {
+					word[i] = byte(r.Uint32())
+				} else {
+					word[i] = delim + byte(r.IntN(3)) - 1
+				}
+			}
+			mask := findDelimiter(binary.LittleEndian.Uint64(word[:]))
+			want := bytes.IndexByte(word[:], delim)
+			got := bits.TrailingZeros64(mask) / 8
+			if want < 0 && mask != 0 || want >= 0 && got != want {
+				t.Fatalf("findDelimiter(%q) with %q = %#x, first delimiter at %d", word, delim, mask, want)
+			}
+		}
+	}
+}
+
+func TestNextNewLineRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for range 2000 {
+		n := 1 + r.IntN(300)
+		data := make([]byte, n+8) // getLongAt reads 8 bytes
+		for i := range n - 1 {
+			if r.IntN(20) == 0 {
+				data[i] = '\n'
+			} else {
+				data[i] = '\n' + byte(r.IntN(3)) - 1
+				if r.IntN(2) == 0 {
+					data[i] = byte(r.Uint32())
+				}
+			}
+		}
+		data[n-1] = '\n'
+		scanner := &Scanner{pointer: unsafe.Pointer(&data[0]), end: uint64(n)}
+		for prev := range n {
+			want := prev + bytes.IndexByte(data[prev:], '\n')
+			if got := nextNewLine(scanner, uint64(prev)); got != uint64(want) {
+				t.Fatalf("nextNewLine(%q, %d) = %d, want %d", data[:n], prev, got, want)
+			}
+		}
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	for _, data := range [][]byte{nil, {}} {
+		if got := format(t, AggregateBytes(data, 4)); got != "{}\n" {
+			t.Errorf("AggregateBytes(%#v) printed %q, want {}", data, got)
+		}
+	}
+	path := writeInput(t, "")
+	results, err := Aggregate(context.Background(), path, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := format(t, results); got != "{}\n" {
+		t.Errorf("Aggregate of a zero-byte file printed %q, want {}", got)
+	}
+	for _, args := range [][]string{{path}, {"-"}} {
+		if stdout, stderr, code := runMain(t, args...); stdout != "{}\n" || code != 0 {
+			t.Errorf("%v: exit %d, stdout %q, stderr %q, want {} and 0", args, code, stdout, stderr)
+		}
+	}
+}
+
+func TestNoTrailingNewline(t *testing.T) {
+	for _, input := range []string{
+		"Hamburg;12.0\nBulawayo;8.9",
+		"Hamburg;12.0\nBulawayo;-99.9",
+		"Hamburg;12.0\n" + strings.Repeat("Bulawayo", 10) + ";1.0",
+	} {
+		want := reference(t, input+"\n")
+		for _, size := range []int64{stealChunkSize, 16} {
+			setFlag(t, &chunkSize, size)
+			if got := aggregate(t, input, 4); got != want {
+				t.Errorf("%q, chunk size %d: got %q, want %q", input, size, got, want)
+			}
+		}
+		path := writeInput(t, input)
+		for _, args := range [][]string{{path}, {"-"}} {
+			if stdout, _, _ := runMainStdin(t, input, args...); stdout != want {
+				t.Errorf("%q, %v: got %q, want %q", input, args, stdout, want)
+			}
+		}
+	}
+}
+
+func TestTabDelimiter(t *testing.T) {
+	input := "Hamburg;12.0\nBulawayo;8.9\nPalembang;38.8\nHamburg;-3.4\n" + strings.Repeat("Bulawayo", 3) + ";1.5\n"
+	want := reference(t, input)
+	tabbed := strings.ReplaceAll(input, ";", "\t")
+
+	t.Cleanup(func() { setDelimiter(';') })
+	setDelimiter('\t')
+	if got := aggregate(t, tabbed, 4); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	setDelimiter(';')
+	if got := aggregate(t, tabbed, 4); got == want {
+		t.Errorf("tab-delimited lines parsed with ';' as the delimiter")
+	}
+
+	path := writeInput(t, tabbed)
+	if stdout, stderr, code := runMain(t, "-delim", `\t`, path); stdout != want || code != 0 {
+		t.Errorf("-delim \\t: exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+}
+
+func TestTwoFilesMerged(t *testing.T) {
+	a := "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n"
+	b := "Hamburg;34.2\nPalembang;38.8\nBulawayo;-8.9\n"
+	setFlag(t, &printCount, true)
+	want := reference(t, a+b)
+	if want != "{Bulawayo=-8.9/0.0/8.9/2, Hamburg=-3.4/14.3/34.2/3, Palembang=38.8/38.8/38.8/1}\n" {
+		t.Fatalf("unexpected reference output %q", want)
+	}
+
+	paths := []string{writeInput(t, a), writeInput(t, b)}
+	results, err := AggregateFiles(context.Background(), paths, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := format(t, results); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if stdout, stderr, code := runMain(t, append([]string{"-with-count"}, paths...)...); stdout != want || code != 0 {
+		t.Errorf("exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+}
+
+// A billion readings of the largest magnitude must not overflow the sums:
+// a thousand parsed records are merged a million times, as worker and file
+// results are.
+func TestBillionRecordSums(t *testing.T) {
+	t.Cleanup(func() { setDecimals(1) })
+	setFlag(t, &printCount, true)
+	setFlag(t, &printStddev, true)
+	for _, tc := range []struct {
+		decimals int
+		max      string
+		reading  int64 // max in 1/tempScale units
+		want     string
+	}{
+		{1, "99.9", 999, "{Max=99.9/99.9/99.9/0.0/1000000000, Min=-99.9/-99.9/-99.9/0.0/1000000000}\n"},
+		{2, "99.99", 9999, "{Max=99.99/99.99/99.99/0.00/1000000000, Min=-99.99/-99.99/-99.99/0.00/1000000000}\n"},
+	} {
+		setDecimals(tc.decimals)
+		input := strings.Repeat("Max;"+tc.max+"\nMin;-"+tc.max+"\n", 1000)
+		part := AggregateBytes([]byte(input), 1)
+		total := make(map[string]*StationData)
+		for name, s := range part {
+			sum := *s
+			for range 999_999 {
+				mergeInto(&sum, s)
+			}
+			total[name] = &sum
+		}
+
+		reading := tc.reading
+		if s := total["Max"]; s.Sum != reading*1e9 || s.SumSq != reading*reading*1e9 || s.Count != 1e9 {
+			t.Errorf("-decimals %d: Sum %d, SumSq %d, Count %d, want %d, %d, 1e9",
+				tc.decimals, s.Sum, s.SumSq, s.Count, reading*1e9, reading*reading*1e9)
+		}
+		if got := format(t, total); got != tc.want {
+			t.Errorf("-decimals %d: got %q, want %q", tc.decimals, got, tc.want)
+		}
+	}
+}
+
+func TestSkipHeader(t *testing.T) {
+	const header = "station;temperature\n"
+	body := strings.Repeat("Hamburg;12.0\nBulawayo;8.9\n", 100)
+	want := reference(t, body)
+
+	setFlag(t, &skipHeader, true)
+	for _, size := range []int64{stealChunkSize, 64} {
+		setFlag(t, &chunkSize, size)
+		if got := aggregate(t, header+body, 4); got != want {
+			t.Errorf("chunk size %d: got %q, want %q", size, got, want)
+		}
+	}
+	results, err := AggregateReader(context.Background(), strings.NewReader(header+body), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := format(t, results); got != want {
+		t.Errorf("streamed: got %q, want %q", got, want)
+	}
+
+	// every input has its own header
+	paths := []string{writeInput(t, header+body), writeInput(t, header+body)}
+	want = reference(t, body+body)
+	if stdout, stderr, code := runMain(t, append([]string{"-skip-header"}, paths...)...); stdout != want || code != 0 {
+		t.Errorf("two files: exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+}
+
+// Far more workers than lines used to leave chunks and sub-scanner
+// segments of zero bytes. The names are long enough that the lines aren't
+// all left to the padded tail.
+func TestMoreWorkersThanLines(t *testing.T) {
+	long := strings.Repeat("Hamburg", 12)
+	input := long + ";12.0\n" + strings.Repeat("Bulawayo", 12) + ";8.9\n" + long + ";-3.4\n"
+	want := reference(t, input)
+	for _, size := range []int64{stealChunkSize, 1} {
+		setFlag(t, &chunkSize, size)
+		for _, scanners := range []int{1, 4, 7} {
+			setFlag(t, &subScanners, scanners)
+			if got := aggregate(t, input, 16); got != want {
+				t.Errorf("chunk size %d, %d sub-scanners: got %q, want %q", size, scanners, got, want)
+			}
+		}
+	}
+	path := writeInput(t, input)
+	if stdout, stderr, code := runMain(t, "-workers", "16", path); stdout != want || code != 0 {
+		t.Errorf("-workers 16: exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+}
+
+var samples = make(map[int64][]byte)
+
+// sample returns rows lines of -generate data, the same for every
+// benchmark and run.
+func sample(b testing.TB, rows int64) []byte {
+	if data, ok := samples[rows]; ok {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := generateMeasurements(&buf, rows, 1); err != nil {
+		b.Fatal(err)
+	}
+	samples[rows] = buf.Bytes()
+	return buf.Bytes()
+}
+
+// manyStations returns rows lines spread over the given number of stations.
+func manyStations(rows, stations int) []byte {
+	var buf bytes.Buffer
+	for i := range rows {
+		fmt.Fprintf(&buf, "station-%06d;%d.%d\n", i*7919%stations, i%199-99, i%10)
+	}
+	return buf.Bytes()
+}
+
+// -no-output aggregates as usual but writes nothing to stdout, only the
+// station count to the log.
+func TestNoOutput(t *testing.T) {
+	path := writeInput(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	stdout, stderr, code := runMain(t, "-no-output", path)
+	if code != 0 || stdout != "" {
+		t.Errorf("exit %d, stdout %q; want 0 and nothing", code, stdout)
+	}
+	if !strings.Contains(stderr, "2 stations") {
+		t.Errorf("stderr %q doesn't log the 2 stations", stderr)
+	}
+	if stdout, stderr, _ := runMain(t, "-no-output", "-quiet", path); stdout != "" || stderr != "" {
+		t.Errorf("-quiet: stdout %q, stderr %q; want nothing", stdout, stderr)
+	}
+}
+
+// skewedInput returns regions*stealChunkSize bytes of -generate lines, except
+// for the regions in longNames, which hold lines with names of 17 to 100
+// bytes, 50000 of them distinct, which take about 1.3 times as long to
+// parse per byte.
+func skewedInput(b testing.TB, regions int, longNames ...int) []byte {
+	short := sample(b, 400_000)
+	var buf bytes.Buffer
+	for r := range regions {
+		end := (r + 1) * stealChunkSize
+		if slices.Contains(longNames, r) {
+			for i := 0; buf.Len() < end; i++ {
+				id := i * 7919 % 50_000
+				name := fmt.Sprintf("%05d-%s", id, strings.Repeat("Llanfairpwllgwyngyll", 5)[:11+id%84])
+				fmt.Fprintf(&buf, "%s;%d.%d\n", name, i%199-99, i%10)
+			}
+			continue
+		}
+		for buf.Len() < end {
+			n := min(end-buf.Len(), len(short))
+			i := bytes.LastIndexByte(short[:n], '\n') + 1
+			if i == 0 {
+				i = bytes.IndexByte(short, '\n') + 1
+			}
+			buf.Write(short[:i])
+		}
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkSkewedSchedule compares the slowest worker's finish time of the
+// size/workers partitioning with that of stealChunkSize chunks pulled from
+// one queue, on an input where two neighbouring regions have long names.
+// Every chunk is timed on its own, the fastest of b.N runs, and both
+// schedules are replayed over those times: a static worker parses its
+// contiguous part, a stealing worker takes the next chunk whenever it is
+// idle. This measures the schedules on a single core, where workers can't
+// run in parallel; per-chunk costs don't depend on the schedule.
+func BenchmarkSkewedSchedule(b *testing.B) {
+	const regions = 16
+	data := skewedInput(b, regions, 4, 5)
+	size := int64(bytes.LastIndexByte(data[:len(data)-streamPadding], '\n') + 1)
+
+	chunkTimes := make([]time.Duration, 0, regions)
+	for offset := int64(0); offset < size; offset += stealChunkSize {
+		chunkTimes = append(chunkTimes, time.Duration(math.MaxInt64))
+	}
+	results := newStationMap(maxNameNum)
+	b.SetBytes(size)
+	for range b.N {
+		for i := range chunkTimes {
+			offset := int64(i) * stealChunkSize
+			results.Reset()
+			started := time.Now()
+			readUsingMMAP(data, results, uint64(offset), stealChunkSize, uint64(min(offset+stealChunkSize+overlapMargin, size)))
+			chunkTimes[i] = min(chunkTimes[i], time.Since(started))
+		}
+	}
+
+	for _, workers := range []int{2, 4, 8} {
+		// static: worker w parses chunks [w*n, (w+1)*n)
+		var static time.Duration
+		perWorker := (len(chunkTimes) + workers - 1) / workers
+		for w := range workers {
+			var busy time.Duration
+			for _, t := range chunkTimes[min(w*perWorker, len(chunkTimes)):min((w+1)*perWorker, len(chunkTimes))] {
+				busy += t
+			}
+			static = max(static, busy)
+		}
+		// stealing: the chunks in offset order, each to the first idle worker
+		free := make([]time.Duration, workers)
+		for _, t := range chunkTimes {
+			idle := slices.Index(free, slices.Min(free))
+			free[idle] += t
+		}
+		b.ReportMetric(float64(static.Microseconds())/1000, fmt.Sprintf("static-ms/%dw", workers))
+		b.ReportMetric(float64(slices.Max(free).Microseconds())/1000, fmt.Sprintf("steal-ms/%dw", workers))
+	}
+}
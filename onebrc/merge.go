@@ -0,0 +1,69 @@
+package onebrc
+
+import "bytes"
+
+// mergeMap folds every station of src into dst. Both maps hold stations of
+// data, keyed by the hash findResult computed, so entries are matched by
+// hash and then by name bytes, and names stay unmaterialized until the
+// final merge into the result map.
+func mergeMap(dst, src *stationMap, data []byte) {
+	src.Range(func(hash uint64, s *StationData) bool {
+		ms, ok := dst.GetUsingHashFunc(hash, func(ms *StationData) bool {
+			return sameName(data, ms, s)
+		})
+		if ok {
+			mergeInto(ms, s)
+		} else {
+			dst.SetUsingHash(hash, s)
+		}
+		return true
+	})
+}
+
+// sameName reports whether stations a and b of data have the same name.
+func sameName(data []byte, a, b *StationData) bool {
+	if a.nameLength != b.nameLength {
+		return false
+	}
+	if a.nameAddress == b.nameAddress {
+		return true
+	}
+	if ignoreCase {
+		return equalFolded(stationName(data, a), stationName(data, b))
+	}
+	return bytes.Equal(stationName(data, a), stationName(data, b))
+}
+
+// stationName returns the name of s, a slice of the data it was parsed from.
+func stationName(data []byte, s *StationData) []byte {
+	return data[s.nameAddress : s.nameAddress+uint64(s.nameLength)]
+}
+
+// reduceMaps merges the n worker maps received from maps pairwise, each pair
+// on its own goroutine, so merges overlap with each other and with workers
+// still parsing instead of running one after another on the caller. It
+// returns the map holding every station, or nil once maps is closed when n
+// is 0, so either way no worker is still running.
+func reduceMaps(maps <-chan *stationMap, n int, data []byte) *stationMap {
+	if n == 0 {
+		for range maps {
+		}
+		return nil
+	}
+	// holds at most the n maps in flight, so sends never block
+	pending := make(chan *stationMap, n)
+	go func() {
+		for m := range maps {
+			pending <- m
+		}
+	}()
+	// every merge turns two maps into one, so n-1 of them leave one map
+	for merges := n - 1; merges > 0; merges-- {
+		a, b := <-pending, <-pending
+		go func() {
+			mergeMap(a, b, data)
+			pending <- a
+		}()
+	}
+	return <-pending
+}
@@ -0,0 +1,72 @@
+package onebrc
+
+import "testing"
+
+// Only the first worker bringing a station copies its name into a string.
+func TestMergeStationNameAllocs(t *testing.T) {
+	finalResult := make(map[string]*StationData)
+	name := []byte("Hamburg")
+	mergeStationName(finalResult, name, &StationData{Count: 1})
+	s := &StationData{Count: 1}
+	allocs := testing.AllocsPerRun(100, func() {
+		mergeStationName(finalResult, name, s)
+	})
+	if allocs != 0 {
+		t.Errorf("merging a known station allocated %v times, want 0", allocs)
+	}
+	if n := finalResult["Hamburg"].Count; n != 102 {
+		t.Errorf("merged count %d, want 102", n)
+	}
+}
+
+// BenchmarkManyStations aggregates 100k stations with 8 workers. With
+// -benchmem it shows about one allocation per station, its name string,
+// however many workers saw the station.
+func BenchmarkManyStations(b *testing.B) {
+	const stations = 100_000
+	data := manyStations(1_000_000, stations)
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for range b.N {
+		if n := len(AggregateBytes(data, 8)); n != stations {
+			b.Fatalf("%d stations, want %d", n, stations)
+		}
+	}
+}
+
+// BenchmarkMerge times the merge phase alone: eight worker maps, parsed
+// before the timer starts, reduced pairwise and copied into the result map.
+// Names are sliced from the input, so the allocations are about one per
+// station, for its name string.
+func BenchmarkMerge(b *testing.B) {
+	const workers, stations = 8, 50_000
+	data := manyStations(400_000, stations)
+	size := uint64(len(data))
+	// the parsers read whole words past the last line
+	data = append(data, make([]byte, streamPadding)...)
+	chunk := size / workers
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for range b.N {
+		b.StopTimer()
+		maps := make(chan *stationMap, workers)
+		for i := range uint64(workers) {
+			m := newStationMap(maxNameNum)
+			readUsingMMAP(data, m, i*chunk, chunk, min((i+1)*chunk+maxLineLen, size))
+			maps <- m
+		}
+		close(maps)
+		b.StartTimer()
+
+		finalResult := make(map[string]*StationData, maxNameNum)
+		reduceMaps(maps, workers, data).Range(func(_ uint64, s *StationData) bool {
+			mergeStationName(finalResult, stationName(data, s), s)
+			return true
+		})
+		if len(finalResult) != stations {
+			b.Fatalf("%d stations, want %d", len(finalResult), stations)
+		}
+	}
+}
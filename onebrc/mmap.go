@@ -0,0 +1,8 @@
+package onebrc
+
+// mappedFile is a read-only memory map of a file or part of it. Each
+// platform provides mapFile and mapAlignment in its own mmap_*.go file.
+type mappedFile interface {
+	data() []byte
+	close() error
+}
@@ -0,0 +1,22 @@
+package onebrc
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// A private read-only mapping behaves like a shared one, no page is ever
+// written, but keeps the scan out of the shared mapping bookkeeping of the
+// unified buffer cache.
+const mapFlags = syscall.MAP_PRIVATE
+
+// uncacheFile sets F_NOCACHE on file with -nocache, so a one-shot scan of a
+// file larger than memory doesn't push everything else out of the cache.
+// It is only a hint, failures are ignored.
+func uncacheFile(file *os.File) {
+	if noCache {
+		_, _ = unix.FcntlInt(file.Fd(), unix.F_NOCACHE, 1)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !unix && !windows
+
+package onebrc
+
+import (
+	"io"
+	"os"
+)
+
+type heapMapping struct {
+	b []byte
+}
+
+// mapAlignment is what mapFile offsets must be a multiple of; reads can
+// start anywhere.
+var mapAlignment int64 = 1
+
+// mapFile reads size bytes of file from offset into memory where there is
+// no mmap, such as js/wasm.
+func mapFile(file *os.File, offset int64, size int64) (mappedFile, error) {
+	b := make([]byte, size)
+	if _, err := file.ReadAt(b, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &heapMapping{b: b}, nil
+}
+
+func (m *heapMapping) data() []byte {
+	return m.b
+}
+
+func (m *heapMapping) close() error {
+	m.b = nil
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package onebrc
+
+import (
+	"os"
+	"syscall"
+)
+
+type unixMapping struct {
+	b []byte
+}
+
+// mapAlignment is what mapFile offsets must be a multiple of.
+var mapAlignment = int64(os.Getpagesize())
+
+// mapFile maps size bytes of file from offset read-only, shared except on
+// macOS, see mapFlags, and prefaulted with -populate.
+func mapFile(file *os.File, offset int64, size int64) (mappedFile, error) {
+	uncacheFile(file)
+	b, err := syscall.Mmap(int(file.Fd()), offset, int(size), syscall.PROT_READ, mapFlags|populateFlags())
+	if err != nil {
+		return nil, err
+	}
+	return &unixMapping{b: b}, nil
+}
+
+func (m *unixMapping) data() []byte {
+	return m.b
+}
+
+func (m *unixMapping) close() error {
+	return syscall.Munmap(m.b)
+}
@@ -0,0 +1,13 @@
+//go:build unix && !darwin
+
+package onebrc
+
+import (
+	"os"
+	"syscall"
+)
+
+const mapFlags = syscall.MAP_SHARED
+
+// uncacheFile is a no-op, -nocache only applies on macOS.
+func uncacheFile(file *os.File) {}
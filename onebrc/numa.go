@@ -0,0 +1,69 @@
+package onebrc
+
+import (
+	"log"
+	"runtime"
+)
+
+// numaAware pins parser workers to NUMA nodes and hands each node a
+// contiguous region of the file. Pages of a file mapping are allocated on
+// the node of the CPU that first faults them in, so a worker that only
+// parses its node's region reads from local memory instead of crossing the
+// interconnect for half of the file.
+//
+// Single-node machines, which includes every single-socket host, take the
+// unpinned path: workers are neither locked to threads nor pinned, and all
+// share one queue, exactly as without -numa. No multi-socket host was
+// available to measure the bandwidth gained; the only Linux host tried had
+// a single node, where -numa changes nothing.
+var numaAware = false
+
+// numaPlacement assigns workers and file regions to NUMA nodes.
+type numaPlacement struct {
+	nodes [][]int
+}
+
+// newNumaPlacement returns the placement for numParsers workers, or nil when
+// there is nothing to gain: -numa is off, the topology is unknown or there
+// is a single node.
+func newNumaPlacement(numParsers int) *numaPlacement {
+	if !numaAware {
+		return nil
+	}
+	return placeOnNodes(numaNodes(), numParsers)
+}
+
+// placeOnNodes returns the placement of numParsers workers on nodes, the
+// CPUs of each node, or nil, the unpinned path, for fewer than two nodes.
+func placeOnNodes(nodes [][]int, numParsers int) *numaPlacement {
+	if len(nodes) <= 1 {
+		return nil
+	}
+	// never leave a node, and so its region, without a worker
+	return &numaPlacement{nodes: nodes[:min(len(nodes), numParsers)]}
+}
+
+// regions is the number of contiguous file regions, one per node in use.
+func (p *numaPlacement) regions() int {
+	if p == nil {
+		return 1
+	}
+	return len(p.nodes)
+}
+
+// nodeOf returns the node, and so the region, of worker out of numParsers.
+func (p *numaPlacement) nodeOf(worker int, numParsers int) int {
+	return worker * p.regions() / numParsers
+}
+
+// pin locks the calling goroutine to its thread and that thread to the CPUs
+// of node. Failing to pin only costs locality, so it is logged, not fatal.
+func (p *numaPlacement) pin(node int) {
+	if p == nil {
+		return
+	}
+	runtime.LockOSThread()
+	if err := pinToCPUs(p.nodes[node]); err != nil {
+		log.Printf("numa: failed to pin worker to node %d: %v", node, err)
+	}
+}
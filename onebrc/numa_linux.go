@@ -0,0 +1,69 @@
+//go:build linux
+
+package onebrc
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// numaNodes returns the CPUs of every NUMA node, read from sysfs. It
+// returns nil when the topology can't be read.
+func numaNodes() [][]int {
+	lists, _ := filepath.Glob("/sys/devices/system/node/node[0-9]*/cpulist")
+	var nodes [][]int
+	for _, list := range lists {
+		b, err := os.ReadFile(list)
+		if err != nil {
+			return nil
+		}
+		cpus, ok := parseCPUList(strings.TrimSpace(string(b)))
+		if !ok {
+			return nil
+		}
+		// memory-only nodes have no CPUs to run workers on
+		if len(cpus) > 0 {
+			nodes = append(nodes, cpus)
+		}
+	}
+	return nodes
+}
+
+// parseCPUList parses the kernel's cpulist format, e.g. "0-3,8-11".
+func parseCPUList(list string) ([]int, bool) {
+	var cpus []int
+	if list == "" {
+		return cpus, true
+	}
+	for _, part := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, false
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(last); err != nil {
+				return nil, false
+			}
+		}
+		for cpu := lo; cpu <= hi; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, true
+}
+
+// pinToCPUs restricts the calling OS thread to cpus. The caller must have
+// locked its goroutine to the thread.
+func pinToCPUs(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+	return unix.SchedSetaffinity(0, &set)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package onebrc
+
+// numaNodes reports no topology, so -numa is a no-op off Linux.
+func numaNodes() [][]int {
+	return nil
+}
+
+func pinToCPUs(cpus []int) error {
+	return nil
+}
@@ -0,0 +1,72 @@
+package onebrc
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Every region's queue must fill on its own: with one sender in offset order
+// the last region's workers got nothing until the first region was queued.
+func TestQueueChunkOffsetsPerRegion(t *testing.T) {
+	const size, chunk = 1000, 10
+	chs := []chan int64{make(chan int64, 2), make(chan int64, 2)}
+	queueChunkOffsets(context.Background(), chs, size, chunk)
+
+	var got []int64
+	for i := len(chs) - 1; i >= 0; i-- {
+		for {
+			select {
+			case offset, ok := <-chs[i]:
+				if ok {
+					got = append(got, offset)
+					continue
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("region %d got no offsets while the others were not drained", i)
+			}
+			break
+		}
+	}
+
+	slices.Sort(got)
+	for i, offset := range got {
+		if offset != int64(i)*chunk {
+			t.Fatalf("offsets %v don't tile [0, %d) in steps of %d", got, size, chunk)
+		}
+	}
+	if len(got) != size/chunk {
+		t.Fatalf("got %d offsets, want %d", len(got), size/chunk)
+	}
+}
+
+// A single node, or an unknown topology, gives the unpinned path: no
+// placement, so one queue for the whole file and pin does nothing.
+func TestSingleNodeUnpinned(t *testing.T) {
+	for _, nodes := range [][][]int{nil, {{0, 1, 2, 3}}} {
+		p := placeOnNodes(nodes, 4)
+		if p != nil {
+			t.Fatalf("%d nodes: got placement %v, want none", len(nodes), p.nodes)
+		}
+		if n := p.regions(); n != 1 {
+			t.Errorf("%d nodes: %d regions, want 1", len(nodes), n)
+		}
+		for worker := range 4 {
+			if node := p.nodeOf(worker, 4); node != 0 {
+				t.Errorf("%d nodes: worker %d on node %d, want 0", len(nodes), worker, node)
+			}
+		}
+		p.pin(0)
+	}
+
+	// two nodes split the workers, and a lone worker still gets a region
+	p := placeOnNodes([][]int{{0, 1}, {2, 3}}, 4)
+	if p.regions() != 2 || p.nodeOf(1, 4) != 0 || p.nodeOf(2, 4) != 1 {
+		t.Errorf("two nodes: %d regions, workers 1 and 2 on nodes %d and %d; want 2, 0 and 1",
+			p.regions(), p.nodeOf(1, 4), p.nodeOf(2, 4))
+	}
+	if p := placeOnNodes([][]int{{0}, {1}}, 1); p.regions() != 1 {
+		t.Errorf("two nodes, one worker: %d regions, want 1", p.regions())
+	}
+}
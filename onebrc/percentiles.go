@@ -0,0 +1,41 @@
+package onebrc
+
+// one bin per tenth of a degree between MIN_TEMP and MAX_TEMP
+const histogramBins = MAX_TEMP - MIN_TEMP + 1
+
+// trackPercentiles makes every station keep a histogram of its readings.
+// It is off by default so the hot loop stays allocation-free.
+var trackPercentiles = false
+
+// histogram counts readings per tenth of a degree. uint32 bins are enough
+// for a billion rows per station and keep each histogram at ~8KB.
+type histogram [histogramBins]uint32
+
+func (h *histogram) add(temp int64) {
+	h[min(max(temp, MIN_TEMP), MAX_TEMP)-MIN_TEMP]++
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i, c := range other {
+		h[i] += c
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile, 0 < p <= 1, of the
+// count readings in h, in tenths of a degree.
+func (h *histogram) percentile(p float64, count int64) int64 {
+	rank := uint64(p * float64(count))
+	if float64(rank) < p*float64(count) {
+		rank++
+	}
+	rank = max(rank, 1)
+
+	var seen uint64
+	for i, c := range h {
+		seen += uint64(c)
+		if seen >= rank {
+			return int64(i) + MIN_TEMP
+		}
+	}
+	return MAX_TEMP
+}
@@ -0,0 +1,69 @@
+package onebrc
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// -percentiles prints the nearest-rank median, p90 and p99 of each station's
+// sorted readings between its min and max.
+func TestPercentiles(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	readings := map[string][]int64{
+		"One":     {42},
+		"Two":     {-5, 5},
+		"Ties":    {10, 10, 10, 20, 20, -30, 10},
+		"Hundred": nil,
+		"Odd":     nil,
+	}
+	for range 100 {
+		readings["Hundred"] = append(readings["Hundred"], rng.Int64N(1999)-999)
+	}
+	for range 37 {
+		readings["Odd"] = append(readings["Odd"], rng.Int64N(201)-100)
+	}
+
+	var input strings.Builder
+	for name, temps := range readings {
+		for _, temp := range temps {
+			fmt.Fprintf(&input, "%s;%.1f\n", name, float64(temp)/10)
+		}
+	}
+
+	var want []string
+	names := make([]string, 0, len(readings))
+	for name := range readings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		sorted := slices.Clone(readings[name])
+		slices.Sort(sorted)
+		fields := []int64{sorted[0]}
+		for _, p := range []float64{0.5, 0.9, 0.99} {
+			rank := int(math.Ceil(p * float64(len(sorted))))
+			fields = append(fields, sorted[max(rank, 1)-1])
+		}
+		fields = append(fields, sorted[len(sorted)-1])
+		formatted := make([]string, len(fields))
+		for i, f := range fields {
+			formatted[i] = fmt.Sprintf("%.1f", float64(f)/10)
+		}
+		want = append(want, name+"="+strings.Join(formatted, "/"))
+	}
+
+	path := writeInput(t, input.String())
+	for _, workers := range []string{"1", "4"} {
+		stdout, stderr, code := runMain(t, "-percentiles", "-workers", workers, path)
+		if code != 0 {
+			t.Fatalf("exit %d, stderr %q", code, stderr)
+		}
+		if w := "{" + strings.Join(want, ", ") + "}\n"; stdout != w {
+			t.Errorf("-workers %s: got %q, want %q", workers, stdout, w)
+		}
+	}
+}
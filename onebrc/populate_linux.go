@@ -0,0 +1,17 @@
+//go:build linux
+
+package onebrc
+
+import "golang.org/x/sys/unix"
+
+// populateFlags returns MAP_POPULATE with -populate, which makes mmap
+// prefault the page tables of the whole mapping up front instead of taking
+// a minor fault per page during the scan. That pays off when the file is
+// already in the page cache; on a cold cache mmap blocks until the whole
+// file has been read in, and the scan can't overlap the reads.
+func populateFlags() int {
+	if populate {
+		return unix.MAP_POPULATE
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package onebrc
+
+// populateFlags is a no-op, -populate only applies on Linux.
+func populateFlags() int {
+	return 0
+}
@@ -0,0 +1,86 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// progress counts the input parsed so far for -progress; nil when off.
+// Workers report once per chunk, never per row, see progressMeter.add.
+var progress *progressMeter
+
+type progressMeter struct {
+	start time.Time
+	bytes atomic.Int64
+	// rows per byte are estimated from the first chunk
+	sampled     atomic.Bool
+	sampleBytes int64
+	sampleRows  atomic.Int64
+	stopCh      chan struct{}
+	done        chan struct{}
+}
+
+func newProgressMeter() *progressMeter {
+	return &progressMeter{start: time.Now(), stopCh: make(chan struct{}), done: make(chan struct{})}
+}
+
+// add records chunk, a block of whole lines, as parsed. It is safe to call
+// on a nil meter.
+func (p *progressMeter) add(chunk []byte) {
+	if p == nil {
+		return
+	}
+	if p.sampled.CompareAndSwap(false, true) {
+		p.sampleBytes = int64(len(chunk))
+		p.sampleRows.Store(int64(bytes.Count(chunk, []byte{'\n'})))
+	}
+	p.bytes.Add(int64(len(chunk)))
+}
+
+// run writes the bytes parsed and the estimated rows/sec to w every second
+// until finish is called.
+func (p *progressMeter) run(w io.Writer) {
+	defer close(p.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			elapsed := time.Since(p.start).Seconds()
+			parsed := p.bytes.Load()
+			var rows int64
+			if rowsSampled := p.sampleRows.Load(); rowsSampled > 0 {
+				rows = int64(float64(parsed) * float64(rowsSampled) / float64(p.sampleBytes))
+			}
+			fmt.Fprintf(w, "progress: %.1f MB, ~%.0f rows/s\n", float64(parsed)/mb, float64(rows)/elapsed)
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// stop stops run without a summary, for runs that failed.
+func (p *progressMeter) stop() {
+	close(p.stopCh)
+	<-p.done
+}
+
+// finish stops run and writes a summary with the exact row count.
+func (p *progressMeter) finish(w io.Writer, rows int64) {
+	p.stop()
+	elapsed := time.Since(p.start)
+	fmt.Fprintf(w, "progress: done, %.1f MB and %d rows in %s, %.0f rows/s\n",
+		float64(p.bytes.Load())/mb, rows, elapsed.Round(time.Millisecond), float64(rows)/elapsed.Seconds())
+}
+
+// totalRows returns the measurements of all stations.
+func totalRows(stations map[string]*StationData) int64 {
+	var rows int64
+	for _, s := range stations {
+		rows += s.Count
+	}
+	return rows
+}
@@ -0,0 +1,23 @@
+package onebrc
+
+import (
+	"strings"
+	"testing"
+)
+
+// Only the parse paths feed the meter, so only they start and finish it:
+// -generate and -lines log nothing.
+func TestProgressOnlyWhileParsing(t *testing.T) {
+	path := writeInput(t, "Hamburg;12.0\nBulawayo;8.9\nHamburg;-3.4\n")
+	if _, stderr, code := runMain(t, "-progress", path); code != 0 || !strings.Contains(stderr, "progress: done") || !strings.Contains(stderr, "3 rows") {
+		t.Errorf("aggregating: exit %d, stderr %q; want the summary of 3 rows", code, stderr)
+	}
+	for _, args := range [][]string{
+		{"-progress", "-generate", "10"},
+		{"-progress", "-lines", path},
+	} {
+		if _, stderr, code := runMain(t, args...); code != 0 || stderr != "" {
+			t.Errorf("%v: exit %d, stderr %q; want nothing", args, code, stderr)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package onebrc
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// randomNames returns n names of 1 to 30 bytes, with shared prefixes and
+// bytes above 0x7f as in UTF-8 names.
+func randomNames(n int) []string {
+	const alphabet = "aAbz \x00\xc3\xa9\xff"
+	r := rand.New(rand.NewPCG(5, 6))
+	names := make([]string, n)
+	for i := range names {
+		name := make([]byte, 1+r.IntN(30))
+		for j := range name {
+			name[j] = alphabet[r.IntN(len(alphabet))]
+		}
+		if i > 0 && r.IntN(4) == 0 {
+			// a prefix or an extension of an earlier name
+			prev := names[r.IntN(i)]
+			name = append([]byte(prev[:r.IntN(len(prev)+1)]), name[:min(len(name), r.IntN(3))]...)
+		}
+		names[i] = string(name)
+	}
+	return names
+}
+
+func TestRadixSortStrings(t *testing.T) {
+	for _, n := range []int{0, 1, 2, radixInsertionCutoff - 1, radixInsertionCutoff, 1000, 50000} {
+		names := randomNames(n)
+		want := slices.Clone(names)
+		sort.Strings(want)
+		radixSortStrings(names)
+		if !slices.Equal(names, want) {
+			t.Errorf("%d names sorted differently from sort.Strings", n)
+		}
+	}
+}
+
+func BenchmarkSortNames(b *testing.B) {
+	for _, n := range []int{10_000, 100_000} {
+		names := randomNames(n)
+		work := make([]string, n)
+		b.Run(fmt.Sprintf("radix/%d", n), func(b *testing.B) {
+			for range b.N {
+				copy(work, names)
+				radixSortStrings(work)
+			}
+		})
+		b.Run(fmt.Sprintf("sort.Strings/%d", n), func(b *testing.B) {
+			for range b.N {
+				copy(work, names)
+				sort.Strings(work)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package onebrc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Reducer aggregates the readings of one station into a custom result, see
+// RegisterReducer. Temperatures are in 1/tempScale units.
+type Reducer interface {
+	Record(temp int64)
+	// Merge adds the readings of other, made by the same factory.
+	Merge(other Reducer)
+	Report() string
+}
+
+// newReducer makes the Reducer of every new station; nil, the default,
+// keeps stations to the unboxed min/mean/max of record.
+var newReducer func() Reducer
+
+// RegisterReducer makes every station feed its readings to a Reducer from
+// factory, alongside min/mean/max, and the output print its Report instead
+// of min/mean/max. nil unregisters it. Call it before aggregating.
+func RegisterReducer(factory func() Reducer) {
+	newReducer = factory
+}
+
+// reducers are the Reducers selectable with -reducer.
+var reducers = map[string]func() Reducer{
+	"sum":  func() Reducer { return new(sumReducer) },
+	"mode": func() Reducer { return make(modeReducer) },
+}
+
+// reducerNames lists the keys of reducers for flag help and errors.
+func reducerNames() string {
+	names := make([]string, 0, len(reducers))
+	for name := range reducers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// setReducer registers the -reducer called name.
+func setReducer(name string) error {
+	factory, ok := reducers[name]
+	if !ok {
+		return fmt.Errorf("invalid -reducer %q: must be one of %s", name, reducerNames())
+	}
+	RegisterReducer(factory)
+	return nil
+}
+
+// sumReducer reports the total of all readings.
+type sumReducer int64
+
+func (r *sumReducer) Record(temp int64)   { *r += sumReducer(temp) }
+func (r *sumReducer) Merge(other Reducer) { *r += *other.(*sumReducer) }
+func (r *sumReducer) Report() string      { return formatTemp(getFloatValue(int64(*r))) }
+
+// modeReducer reports the most frequent reading, the lowest on ties.
+type modeReducer map[int64]int64
+
+func (r modeReducer) Record(temp int64) { r[temp]++ }
+
+func (r modeReducer) Merge(other Reducer) {
+	for temp, n := range other.(modeReducer) {
+		r[temp] += n
+	}
+}
+
+func (r modeReducer) Report() string {
+	var mode, most int64
+	for temp, n := range r {
+		if n > most || n == most && temp < mode {
+			mode, most = temp, n
+		}
+	}
+	return formatTemp(getFloatValue(mode))
+}
@@ -0,0 +1,51 @@
+package onebrc
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// keepReducer keeps every reading it is given.
+type keepReducer struct{ temps []int64 }
+
+func (r *keepReducer) Record(temp int64)   { r.temps = append(r.temps, temp) }
+func (r *keepReducer) Merge(other Reducer) { r.temps = append(r.temps, other.(*keepReducer).temps...) }
+func (r *keepReducer) Report() string      { return strconv.Itoa(len(r.temps)) }
+
+// A registered reducer gets every reading of its station exactly once,
+// however the input is split between workers and merged.
+func TestReducerSeesEveryRecord(t *testing.T) {
+	RegisterReducer(func() Reducer { return new(keepReducer) })
+	t.Cleanup(func() { RegisterReducer(nil) })
+
+	input := string(sample(t, 50_000))
+	want := make(map[string][]int64)
+	for _, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		name, temp, _ := strings.Cut(line, ";")
+		f, err := strconv.ParseFloat(temp, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[name] = append(want[name], fixedPoint(f))
+	}
+
+	for _, shared := range []bool{false, true} {
+		setFlag(t, &useSharedMap, shared)
+		for _, workers := range []int{1, 4} {
+			results := AggregateBytes([]byte(input), workers)
+			if len(results) != len(want) {
+				t.Fatalf("shared map %v, %d workers: %d stations, want %d", shared, workers, len(results), len(want))
+			}
+			for name, temps := range want {
+				got := slices.Clone(results[name].Reducer.(*keepReducer).temps)
+				slices.Sort(got)
+				slices.Sort(temps)
+				if !slices.Equal(got, temps) {
+					t.Errorf("shared map %v, %d workers: %s got %d readings, want %d", shared, workers, name, len(got), len(temps))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package onebrc
+
+import (
+	"fmt"
+	"math"
+)
+
+// roundingMode is how means are rounded to outputDecimals places, see
+// -rounding. Other implementations round differently, e.g. with
+// RoundingMode.HALF_EVEN or by truncating, and this lets the output match
+// theirs. Min and max are exact readings and need no rounding.
+type roundingMode int
+
+const (
+	// half up, toward positive infinity, like the reference's Math.round
+	roundHalfUp roundingMode = iota
+	roundHalfEven
+	// toward zero
+	roundTrunc
+	// toward positive infinity
+	roundCeil
+)
+
+var meanRounding = roundHalfUp
+
+// setRounding selects the rounding of means: halfup, halfeven, trunc or ceil.
+func setRounding(mode string) error {
+	switch mode {
+	case "halfup":
+		meanRounding = roundHalfUp
+	case "halfeven":
+		meanRounding = roundHalfEven
+	case "trunc":
+		meanRounding = roundTrunc
+	case "ceil":
+		meanRounding = roundCeil
+	default:
+		return fmt.Errorf("unknown rounding %q: must be halfup, halfeven, trunc or ceil", mode)
+	}
+	return nil
+}
+
+// roundQuotient returns num/den rounded to an integer by meanRounding.
+// den must be positive.
+func roundQuotient(num, den int64) int64 {
+	q, r := num/den, num%den
+	// floor the quotient, so r is in [0, den)
+	if r < 0 {
+		q--
+		r += den
+	}
+	if r == 0 {
+		return q
+	}
+	switch meanRounding {
+	case roundHalfEven:
+		if 2*r > den || 2*r == den && q%2 != 0 {
+			q++
+		}
+	case roundTrunc:
+		if num < 0 {
+			q++
+		}
+	case roundCeil:
+		q++
+	default:
+		if 2*r >= den {
+			q++
+		}
+	}
+	return q
+}
+
+// roundMean rounds x to outputDecimals places by meanRounding, for means
+// too large to divide exactly in integers.
+func roundMean(x float64) float64 {
+	switch meanRounding {
+	case roundHalfEven:
+		return math.RoundToEven(x*outputScale) / outputScale
+	case roundTrunc:
+		return math.Trunc(x*outputScale) / outputScale
+	case roundCeil:
+		return math.Ceil(x*outputScale) / outputScale
+	default:
+		return round(x)
+	}
+}
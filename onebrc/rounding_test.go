@@ -0,0 +1,109 @@
+package onebrc
+
+import (
+	"math"
+	"testing"
+)
+
+// Means are divided in integers and rounded half up, toward positive
+// infinity, like the reference's Math.round. The float quotient of a tie is
+// often just below it and used to round the wrong way.
+func TestAverageHalfUp(t *testing.T) {
+	for _, tc := range []struct {
+		sum, count int64
+		want       string
+	}{
+		{-1, 2, "0.0"},   // -0.05
+		{49, 2, "2.5"},   // 2.45
+		{-49, 2, "-2.4"}, // -2.45
+		{-1993, 2, "-99.6"},
+		{1993, 2, "99.7"},
+		{10, 3, "0.3"},
+		{-10, 3, "-0.3"},
+		{999 * 1_000_000_000, 1_000_000_000, "99.9"},
+	} {
+		s := &StationData{Sum: tc.sum, Count: tc.count}
+		if got := formatTemp(average(s)); got != tc.want {
+			t.Errorf("mean of sum %d over %d = %s, want %s", tc.sum, tc.count, got, tc.want)
+		}
+	}
+}
+
+func TestAverageRoundingModes(t *testing.T) {
+	// sums are in tenths, so most of these means have a hundredths digit
+	means := []struct{ sum, count int64 }{
+		{49, 2},  // 2.45
+		{-49, 2}, // -2.45
+		{47, 2},  // 2.35
+		{-1, 2},  // -0.05
+		{247, 10},
+		{-247, 10},
+		// too large to divide in integers, rounded as floats
+		{494e16, 2e17},
+		{-494e16, 2e17},
+	}
+	for _, tc := range []struct {
+		mode string
+		want []string
+	}{
+		{"halfup", []string{"2.5", "-2.4", "2.4", "0.0", "2.5", "-2.5", "2.5", "-2.5"}},
+		{"halfeven", []string{"2.4", "-2.4", "2.4", "0.0", "2.5", "-2.5", "2.5", "-2.5"}},
+		{"trunc", []string{"2.4", "-2.4", "2.3", "0.0", "2.4", "-2.4", "2.4", "-2.4"}},
+		{"ceil", []string{"2.5", "-2.4", "2.4", "0.0", "2.5", "-2.4", "2.5", "-2.4"}},
+	} {
+		setFlag(t, &meanRounding, meanRounding)
+		if err := setRounding(tc.mode); err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range means {
+			if got := formatTemp(average(&StationData{Sum: m.sum, Count: m.count})); got != tc.want[i] {
+				t.Errorf("-rounding %s: mean of sum %d over %d = %s, want %s", tc.mode, m.sum, m.count, got, tc.want[i])
+			}
+		}
+	}
+	if err := setRounding("up"); err == nil {
+		t.Error("setRounding accepted an unknown mode")
+	}
+}
+
+func TestRoundingFlag(t *testing.T) {
+	path := writeInput(t, "Hamburg;2.4\nHamburg;2.5\n")
+	for mode, want := range map[string]string{
+		"halfup":   "{Hamburg=2.4/2.5/2.5}\n",
+		"halfeven": "{Hamburg=2.4/2.4/2.5}\n",
+		"trunc":    "{Hamburg=2.4/2.4/2.5}\n",
+		"ceil":     "{Hamburg=2.4/2.5/2.5}\n",
+	} {
+		if stdout, stderr, code := runMain(t, "-rounding", mode, path); stdout != want || code != 0 {
+			t.Errorf("-rounding %s: exit %d, got %q, want %q, stderr %q", mode, code, stdout, want, stderr)
+		}
+	}
+	if _, _, code := runMain(t, "-rounding", "up", path); code == 0 {
+		t.Error("-rounding up exited with 0")
+	}
+}
+
+// The reference prints Math.round's long, which has no negative zero, so
+// neither a reading nor a mean straddling zero may print as -0.0.
+func TestNoNegativeZero(t *testing.T) {
+	for _, f := range []float64{math.Copysign(0, -1), -0.01, -0.04, -0.05} {
+		if got := formatTemp(f); got != "0.0" {
+			t.Errorf("formatTemp(%v) = %s, want 0.0", f, got)
+		}
+	}
+
+	// A reads -0.0, B averages to -0.05, C to -0.03
+	input := "A;-0.0\nB;-0.1\nB;0.0\nC;-0.1\nC;0.0\nC;0.0\n"
+	for format, want := range map[string]string{
+		"text": "{A=0.0/0.0/0.0, B=-0.1/0.0/0.0, C=-0.1/0.0/0.0}\n",
+		"json": `{"A":{"min":0.0,"mean":0.0,"max":0.0,"count":1},` +
+			`"B":{"min":-0.1,"mean":0.0,"max":0.0,"count":2},` +
+			`"C":{"min":-0.1,"mean":0.0,"max":0.0,"count":3}}` + "\n",
+		"csv": "station,min,mean,max,count\nA,0.0,0.0,0.0,1\nB,-0.1,0.0,0.0,2\nC,-0.1,0.0,0.0,3\n",
+	} {
+		setFlag(t, &outputFormat, format)
+		if got := aggregate(t, input, 1); got != want {
+			t.Errorf("-format %s: got %q, want %q", format, got, want)
+		}
+	}
+}
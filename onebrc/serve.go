@@ -0,0 +1,63 @@
+package onebrc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+// serve answers POST /aggregate on addr with the JSON results of the
+// measurements in the request body, see -serve. It only returns on error.
+func serve(addr string, workers int) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/aggregate", func(w http.ResponseWriter, r *http.Request) {
+		handleAggregate(w, r, workers)
+	})
+	log.Printf("serving POST /aggregate on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleAggregate streams the request body through the parser, so bodies
+// of any size use the memory of a few blocks rather than their own. A body
+// sent with Content-Encoding: gzip is decompressed on the fly.
+func handleAggregate(w http.ResponseWriter, r *http.Request, workers int) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read gzip body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	// AggregateReader runs one request at a time, see runMu; every run
+	// already uses all workers, so queuing costs little throughput
+	result, err := AggregateReader(r.Context(), body, workers)
+	if errors.Is(err, context.Canceled) {
+		// the client went away, nobody is left to answer
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writer := bufio.NewWriterSize(w, outputBufferSize)
+	writeJSON(writer, sortNames(selectNames(result), result), result)
+	if err := writer.Flush(); err != nil {
+		log.Printf("failed to write response to %s: %v", r.RemoteAddr, err)
+	}
+}
@@ -0,0 +1,41 @@
+package onebrc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Each POST /aggregate answers with the results of its own body only: the
+// second request shares a station with the first but none of its readings.
+func TestServeAggregate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAggregate(w, r, 2)
+	}))
+	defer srv.Close()
+
+	bodies := []string{
+		string(sample(t, 20_000)),
+		"Hamburg;-12.0\nZurich;3.4\nHamburg;40.1\n",
+	}
+	for i, body := range bodies {
+		resp, err := http.Post(srv.URL+"/aggregate", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: status %d, body %q", i, resp.StatusCode, out)
+		}
+		got, _ := decodeJSON(t, string(out))
+		if want := reference(t, body); got != want {
+			t.Errorf("request %d: got %.300q, want %.300q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package onebrc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptContext returns a context canceled by the first SIGINT or
+// SIGTERM, so the run stops cleanly: no more chunks are handed out, the
+// workers finish the ones they hold, so nothing reads the mapping once it
+// is unmapped, and the aggregation returns context.Canceled. A second
+// signal kills the process as usual.
+//
+// The handler only belongs around the parsing: stop unregisters it and
+// cancels the context, so a signal while results are printed kills the
+// process as usual too.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
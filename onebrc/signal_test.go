@@ -0,0 +1,93 @@
+package onebrc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// SIGINT mid-run stops the command with exit code 130 and no results. The
+// input is piped in so the signal is sent once the command has read two
+// blocks, well after it installed its handler.
+func TestInterruptStopsCleanly(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGINT to send on windows")
+	}
+	cmd := exec.Command(os.Args[0], "-workers", "2", "-")
+	cmd.Env = append(os.Environ(), "ONEBRC_RUN_MAIN=1")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	block := bytes.Repeat([]byte("Hamburg;12.0\nBulawayo;8.9\n"), streamChunkSize/26)
+	for range 2 {
+		if _, err := stdin.Write(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	// more input wakes up the blocked read, which then sees the cancel
+	stdin.Write(block)
+	stdin.Close()
+
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	if code := cmd.ProcessState.ExitCode(); code != 130 {
+		t.Errorf("exit %d, want 130; stderr %q", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "interrupted") || stdout.Len() != 0 {
+		t.Errorf("stdout %.100q, stderr %q; want no results and the interruption logged", stdout.String(), stderr.String())
+	}
+}
+
+// Once the input is parsed the handler is gone, so SIGINT while results are
+// printed kills the command as usual rather than being swallowed. Output
+// well past a pipe buffer that isn't read keeps the command printing.
+func TestInterruptWhilePrinting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGINT to send on windows")
+	}
+	var input bytes.Buffer
+	for i := range 50_000 {
+		fmt.Fprintf(&input, "station%05d;1.0\n", i)
+	}
+	cmd := exec.Command(os.Args[0], writeInput(t, input.String()))
+	cmd.Env = append(os.Environ(), "ONEBRC_RUN_MAIN=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// the first bytes of the results mean parsing is over
+	if _, err := stdout.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, stdout)
+
+	cmd.Wait()
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); !ok || !status.Signaled() || status.Signal() != syscall.SIGINT {
+		t.Errorf("%v, want killed by SIGINT", cmd.ProcessState)
+	}
+}
@@ -0,0 +1,37 @@
+package onebrc
+
+import "github.com/nbukhari/1brc/internal/fasthash"
+
+// stations handed out per slab allocation
+const slabSize = 1024
+
+// stationSlab hands out StationData from large blocks, so the garbage
+// collector tracks a few big allocations instead of one per station per
+// worker. A block stays alive as long as any station in it is referenced,
+// which covers the merge. Every worker has its own slab, so it needs no
+// lock and its blocks go away with the worker's results.
+type stationSlab struct {
+	free []StationData
+}
+
+// alloc returns a zeroed StationData.
+func (s *stationSlab) alloc() *StationData {
+	if len(s.free) == 0 {
+		s.free = make([]StationData, slabSize)
+	}
+	station := &s.free[0]
+	s.free = s.free[1:]
+	return station
+}
+
+// stationMap is the station map of one worker and the slab its stations
+// are allocated from.
+type stationMap struct {
+	*fasthash.Map[string, *StationData]
+	slab stationSlab
+}
+
+// newStationMap returns an empty stationMap with room for size stations.
+func newStationMap(size uint64) *stationMap {
+	return &stationMap{Map: fasthash.NewHashMap[string, *StationData](size, size*fasthash.BucketsPerKey)}
+}
@@ -0,0 +1,46 @@
+package onebrc
+
+import "testing"
+
+func TestSlabAlloc(t *testing.T) {
+	var s stationSlab
+	// AllocsPerRun calls the function once more to warm up
+	stations := make([]*StationData, 0, 11*slabSize)
+	allocs := testing.AllocsPerRun(10, func() {
+		for range slabSize {
+			stations = append(stations, s.alloc())
+		}
+	})
+	if allocs != 1 {
+		t.Errorf("%d stations took %v allocations, want 1", slabSize, allocs)
+	}
+
+	seen := make(map[*StationData]bool)
+	for _, station := range stations {
+		if *station != (StationData{}) || seen[station] {
+			t.Fatal("alloc returned a used station")
+		}
+		seen[station] = true
+		station.Count = 1
+	}
+}
+
+// BenchmarkStationAlloc compares stations from a slab with one heap
+// allocation each; see -benchmem.
+func BenchmarkStationAlloc(b *testing.B) {
+	var sink *StationData
+	b.Run("slab", func(b *testing.B) {
+		var s stationSlab
+		b.ReportAllocs()
+		for range b.N {
+			sink = s.alloc()
+		}
+	})
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			sink = new(StationData)
+		}
+	})
+	_ = sink
+}
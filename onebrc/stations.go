@@ -0,0 +1,33 @@
+package onebrc
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// maxStations is the most distinct stations an input may have before the
+// run is aborted, see -max-stations; 0 disables the limit. A wrong
+// delimiter or binary data turns nearly every line into a new station,
+// which would otherwise grow the worker maps until memory runs out.
+var maxStations = 1_000_000
+
+// tooManyStations is set by the first worker whose map passes maxStations,
+// stopping the run like a canceled context.
+var tooManyStations atomic.Bool
+
+// checkStations records whether a map of n stations passes maxStations.
+func checkStations(n int) {
+	if maxStations > 0 && n > maxStations {
+		tooManyStations.Store(true)
+	}
+}
+
+// stopping reports whether workers should take no more input.
+func stopping(ctx context.Context) bool {
+	return ctx.Err() != nil || tooManyStations.Load()
+}
+
+func errTooManyStations(path string) error {
+	return fmt.Errorf("failed to read %s file: more than %d distinct stations, the input is likely malformed (see -max-stations)", path, maxStations)
+}
@@ -0,0 +1,33 @@
+package onebrc
+
+import (
+	"strings"
+	"testing"
+)
+
+// An input with too many stations stops the workers after the chunk that
+// passes -max-stations, with per-worker maps and with the shared map.
+func TestMaxStationsStopsWorkers(t *testing.T) {
+	const rows = 200_000
+	data := manyStations(rows, 5000)
+	path := writeInput(t, string(data))
+	setFlag(t, &maxStations, 100)
+	setFlag(t, &chunkSize, 64*1024)
+	t.Cleanup(func() { tooManyStations.Store(false) })
+
+	for _, shared := range []bool{false, true} {
+		setFlag(t, &useSharedMap, shared)
+		tooManyStations.Store(false)
+		if parsed := totalCount(AggregateBytes(data, 1)); !tooManyStations.Load() || parsed >= rows {
+			t.Errorf("shared map %v: parsed %d of %d rows, stopped %v", shared, parsed, rows, tooManyStations.Load())
+		}
+
+		args := []string{"-max-stations", "100", path}
+		if shared {
+			args = append([]string{"-shared-map"}, args...)
+		}
+		if stdout, stderr, code := runMain(t, args...); code == 0 || stdout != "" || !strings.Contains(stderr, "more than 100 distinct stations") {
+			t.Errorf("shared map %v: exit %d, stdout %q, stderr %q", shared, code, stdout, stderr)
+		}
+	}
+}
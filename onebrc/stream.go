@@ -0,0 +1,150 @@
+package onebrc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+const (
+	// size of each block read from a non-mmapable input
+	streamChunkSize = 8 * mb
+	// zeroed tail after every block so the word-at-a-time reads in
+	// readUsingMMAP never leave the allocation
+	streamPadding = 64
+)
+
+// createStreamWorkers aggregates input that can't be mapped, such as stdin or
+// a named pipe. The reader is cut into blocks ending on a line boundary and
+// each block is parsed with the same code as the mmap path.
+func createStreamWorkers(ctx context.Context, r io.Reader, numParsers int, finalResult map[string]*StationData, report *strictReport) error {
+	lastRun.workers = max(lastRun.workers, numParsers)
+	chunkCh := make(chan streamChunk, numParsers)
+	chunkStatsCh := make(chan map[string]*StationData, numParsers)
+
+	var readErr error
+	var read uint64
+	go func() {
+		read, readErr = readChunks(ctx, r, chunkCh, report)
+		close(chunkCh)
+	}()
+
+	wg := sync.WaitGroup{}
+	wg.Add(numParsers)
+	for i := 0; i < numParsers; i++ {
+		go func() {
+			results := newStationMap(maxNameNum)
+			local := make(map[string]*StationData, maxNameNum)
+			for chunk := range chunkCh {
+				// drain the queue without parsing once canceled
+				if ctx.Err() != nil {
+					continue
+				}
+				bad, malformed := aggregateChunk(chunk.data, chunk.offset, results, local)
+				report.addBlock(chunk.data, chunk.firstLine, chunk.offset, bad, malformed)
+				progress.add(chunk.data)
+				checkStations(len(local))
+			}
+			chunkStatsCh <- local
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(chunkStatsCh)
+	}()
+
+	for chunkStats := range chunkStatsCh {
+		for _, s := range chunkStats {
+			mergeStation(finalResult, s)
+		}
+	}
+	inputBase += read
+	lastRun.bytes += read
+	return readErr
+}
+
+// streamChunk is a block of whole lines cut from a stream.
+type streamChunk struct {
+	data []byte
+	// line number of data[0], only counted in strictMode
+	firstLine int
+	// position of data[0] in the stream
+	offset uint64
+}
+
+// readChunks sends r to chunkCh in blocks that each end with a newline and
+// returns the number of bytes sent. A final line without one gets a newline
+// appended.
+func readChunks(ctx context.Context, r io.Reader, chunkCh chan<- streamChunk, report *strictReport) (uint64, error) {
+	var leftover []byte
+	var offset uint64
+	line := 1
+	header := skipHeader
+	sniffed := false
+	for {
+		buf := make([]byte, len(leftover)+streamChunkSize+streamPadding)
+		n := copy(buf, leftover)
+		read, err := io.ReadFull(r, buf[n:n+streamChunkSize])
+		n += read
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return offset, err
+		}
+		if !sniffed {
+			sniffed = true
+			if err := checkBinary(buf[:n]); err != nil {
+				return offset, err
+			}
+		}
+
+		end := bytes.LastIndexByte(buf[:n], '\n') + 1
+		if eof && end < n {
+			buf[n] = '\n'
+			n++
+			end = n
+		}
+		leftover = buf[end:n]
+		start := 0
+		if header && end > 0 {
+			header = false
+			start = end - len(cutHeader(buf[:end], report))
+			offset += uint64(start)
+		}
+		if start < end {
+			chunkCh <- streamChunk{data: buf[start:end], firstLine: line, offset: offset}
+			offset += uint64(end - start)
+			if strictMode {
+				line += bytes.Count(buf[start:end], []byte{'\n'})
+			}
+		}
+		if eof || stopping(ctx) {
+			return offset, nil
+		}
+	}
+}
+
+// aggregateChunk parses chunk, which must end with a newline and be followed
+// by streamPadding readable bytes, and merges its stations into dst. offset
+// is the position of chunk in its input. Names point into chunk, so they are
+// materialized before results is reset. In strictMode the malformed lines of
+// chunk are counted and the first of them returned, see readStrict.
+func aggregateChunk(chunk []byte, offset uint64, results *stationMap, dst map[string]*StationData) ([]malformedRecord, int) {
+	var bad []malformedRecord
+	malformed := 0
+	size := uint64(len(chunk))
+	if strictMode {
+		bad, malformed = readStrict(chunk, results, 0, size, size)
+	} else {
+		readUsingMMAP(chunk, results, 0, size, size)
+	}
+	results.Range(func(_ uint64, s *StationData) bool {
+		s.firstSeen += offset
+		mergeStationName(dst, stationName(chunk, s), s)
+		return true
+	})
+	results.Reset()
+	return bad, malformed
+}
@@ -0,0 +1,223 @@
+package onebrc
+
+import (
+	"bytes"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// strictMode validates every record before aggregating it and skips the
+// malformed ones; see readStrict. It is off by default because the
+// byte-by-byte check is far slower than the branchless fast path.
+var strictMode = false
+
+// validateOnly turns -strict into a check of the input alone: names over
+// maxNameLen bytes are malformed too and nothing is printed on success.
+var validateOnly = false
+
+// malformed records found across all inputs, see strictReport.print
+var malformedTotal atomic.Int64
+
+// maximum number of malformed lines listed in a report, and kept per input
+const maxReportedLines = 10
+
+// malformedRecord is a line that failed validation.
+type malformedRecord struct {
+	offset uint64 // of the line start, relative to the input
+	line   int    // 1-based, 0 until numbered, see strictReport.numberLines
+	reason string
+}
+
+// strictReport collects the malformed records of one input across workers.
+// Only the first maxReportedLines by offset are kept, the others are only
+// counted, so a file that is malformed throughout is checked in constant
+// memory.
+type strictReport struct {
+	mu      sync.Mutex
+	records []malformedRecord
+	count   atomic.Int64
+	// lines before the parsed data, such as a header cut by -skip-header
+	skippedLines int
+	// file offset of the parsed data when it is a -start window past the
+	// first record; lines are then numbered from the window, see print
+	windowBase int64
+}
+
+// add counts n malformed records, bad being the first of them in offset
+// order, and keeps bad if they are among the first of the input.
+func (r *strictReport) add(bad []malformedRecord, n int) {
+	if r == nil || n == 0 {
+		return
+	}
+	r.count.Add(int64(n))
+	r.mu.Lock()
+	r.records = append(r.records, bad...)
+	sort.Slice(r.records, func(i, j int) bool { return r.records[i].offset < r.records[j].offset })
+	r.records = r.records[:min(len(r.records), maxReportedLines)]
+	r.mu.Unlock()
+}
+
+// addBlock is add for records whose offsets are relative to block, which
+// starts at offset in the input on line firstLine. It numbers them from
+// block alone, so streams are never counted from their start.
+func (r *strictReport) addBlock(block []byte, firstLine int, offset uint64, bad []malformedRecord, n int) {
+	if r == nil || n == 0 {
+		return
+	}
+	line, prev := firstLine+r.skippedLines, uint64(0)
+	for i := range bad {
+		line += bytes.Count(block[prev:bad[i].offset], []byte{'\n'})
+		prev = bad[i].offset
+		bad[i].line = line
+		bad[i].offset += offset
+	}
+	r.add(bad, n)
+}
+
+// numberLines numbers the kept records not numbered yet, whose offsets are
+// relative to data, the whole input. Only data up to the last of them is
+// counted, once, after the workers are done.
+func (r *strictReport) numberLines(data []byte) {
+	if r == nil {
+		return
+	}
+	line, prev := 1+r.skippedLines, uint64(0)
+	for i := range r.records {
+		rec := &r.records[i]
+		line += bytes.Count(data[prev:rec.offset], []byte{'\n'})
+		prev = rec.offset
+		if rec.line == 0 {
+			rec.line = line
+		}
+	}
+}
+
+// print summarizes the malformed records of path on stderr.
+func (r *strictReport) print(path string) {
+	if r == nil || r.count.Load() == 0 {
+		return
+	}
+	n := r.count.Load()
+	malformedTotal.Add(n)
+	verb := "skipped"
+	if validateOnly {
+		verb = "found"
+	}
+	log.Printf("%s: %s %d malformed records", path, verb, n)
+	for _, rec := range r.records {
+		// numbering from the start of the file would mean reading all of
+		// it up to the window, so the line's byte is given instead
+		if r.windowBase > 0 {
+			log.Printf("  line %d of the window, at byte %d: %s", rec.line, r.windowBase+int64(rec.offset), rec.reason)
+		} else {
+			log.Printf("  line %d: %s", rec.line, rec.reason)
+		}
+	}
+}
+
+// checkRecord validates the line starting at pos, which must end with a
+// newline at or before end, against name;-?d?d.d, with tempDecimals digits
+// after the '.', and returns the position of that newline along with the
+// reason the line is malformed, if any.
+func checkRecord(scanner *Scanner, pos uint64, end uint64) (uint64, string) {
+	lineEnd := pos
+	for lineEnd < end && scanner.getByteAt(lineEnd) != '\n' {
+		lineEnd++
+	}
+
+	// with a \r\n line ending the temperature stops before the '\r'
+	valueEnd := lineEnd
+	if valueEnd > pos && scanner.getByteAt(valueEnd-1) == '\r' {
+		valueEnd--
+	}
+
+	i := pos
+	for i < valueEnd && scanner.getByteAt(i) != delimiter {
+		i++
+	}
+	switch {
+	case i == valueEnd:
+		return lineEnd, "missing delimiter"
+	case i == pos:
+		return lineEnd, "empty station name"
+	case validateOnly && i-pos > maxNameLen:
+		return lineEnd, "station name over 100 bytes"
+	}
+
+	// with -extra-fields the temperature stops at the next delimiter
+	if extraFields {
+		for j := i + 1; j < valueEnd; j++ {
+			if scanner.getByteAt(j) == delimiter {
+				valueEnd = j
+				break
+			}
+		}
+	}
+
+	// temperature: optional sign, up to two digits, then the decimal
+	// separator and tempDecimals digits unless it is a plain integer; see
+	// scanLenient
+	i++
+	if i < valueEnd && scanner.getByteAt(i) == '-' {
+		i++
+	}
+	digits := uint64(0)
+	for i < valueEnd && isDigit(scanner.getByteAt(i)) {
+		i++
+		digits++
+	}
+	if digits > 0 && digits <= 2 && i == valueEnd {
+		return lineEnd, ""
+	}
+	if digits > 2 || valueEnd-i != uint64(tempDecimals)+1 || scanner.getByteAt(i) != decimalSeparator {
+		return lineEnd, "malformed temperature"
+	}
+	for i++; i < valueEnd; i++ {
+		if !isDigit(scanner.getByteAt(i)) {
+			return lineEnd, "malformed temperature"
+		}
+	}
+	return lineEnd, ""
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// readStrict is the validating counterpart of readUsingMMAP. Each line is
+// checked with checkRecord before it is handed to the fast parser, and the
+// malformed ones are counted instead of aggregated. The first
+// maxReportedLines of them are returned with the count.
+func readStrict(data []byte, results *stationMap, offset uint64, bytesToRead uint64, maxAvailable uint64) ([]malformedRecord, int) {
+	scanner := &Scanner{pointer: unsafe.Pointer(&data[0]), position: offset, end: maxAvailable}
+	segmentStart, segmentEnd := segmentBounds(scanner, offset, bytesToRead, maxAvailable)
+	// the segment's last line may cross maxAvailable; hashName stops long
+	// names at scanner.end, which must be the '\n' ending the segment
+	scanner.end = segmentEnd
+
+	var bad []malformedRecord
+	malformed := 0
+	pos := segmentStart
+	for pos < segmentEnd {
+		lineEnd, reason := checkRecord(scanner, pos, segmentEnd)
+		if reason != "" {
+			if malformed < maxReportedLines {
+				bad = append(bad, malformedRecord{offset: pos, reason: reason})
+			}
+			malformed++
+		} else {
+			scanner.position = pos
+			word := scanner.getLong()
+			wordB := scanner.getLongAt(pos + 8)
+			record(findResult(word, findDelimiter(word), wordB, findDelimiter(wordB), scanner, results), scanNumber(scanner))
+		}
+		pos = lineEnd + 1
+	}
+	if verifyCoverage {
+		addCoverage(pos - segmentStart)
+	}
+	return bad, malformed
+}
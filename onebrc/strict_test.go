@@ -0,0 +1,124 @@
+package onebrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Names past 16 bytes take hashName's slow path, which stops at the end of
+// the scanner. In strictMode that end must be the segment's last '\n', not
+// maxAvailable, or a line crossing maxAvailable loses the rest of its name.
+func TestStrictLongNamesAcrossChunks(t *testing.T) {
+	var input strings.Builder
+	for i := range 2000 {
+		fmt.Fprintf(&input, "N%03d%s;%d.%d\n", i%20, strings.Repeat("M", 246), i%100-50, i%10)
+	}
+	want := reference(t, input.String())
+
+	setFlag(t, &chunkSize, 1000)
+	setFlag(t, &strictMode, true)
+	if got := aggregate(t, input.String(), 4); got != want {
+		t.Errorf("got %d bytes of output, want %d:\n%.300s", len(got), len(want), got)
+	}
+}
+
+// captureLog returns what the standard logger writes until t ends.
+func captureLog(t testing.TB) *strings.Builder {
+	var b strings.Builder
+	log.SetOutput(&b)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &b
+}
+
+// A report keeps only the first maxReportedLines records, numbered across
+// chunks, but counts them all.
+func TestStrictReportKeepsFirstRecords(t *testing.T) {
+	var input strings.Builder
+	var badLines []int
+	for line := 1; line <= 5000; line++ {
+		if line%7 == 3 {
+			fmt.Fprintf(&input, "broken %d\n", line)
+			badLines = append(badLines, line)
+		} else {
+			fmt.Fprintf(&input, "S%d;%d.5\n", line%13, line%90)
+		}
+	}
+	setFlag(t, &chunkSize, 512)
+	setFlag(t, &strictMode, true)
+	want := fmt.Sprintf("input: skipped %d malformed records\n", len(badLines))
+	for _, line := range badLines[:maxReportedLines] {
+		want += fmt.Sprintf("  line %d: missing delimiter\n", line)
+	}
+
+	for _, stream := range []bool{false, true} {
+		logged := captureLog(t)
+		if stream {
+			if _, err := AggregateReader(context.Background(), strings.NewReader(input.String()), 4); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			AggregateBytes([]byte(input.String()), 4)
+		}
+		// the count is per run, see beginRun
+		if n := malformedTotal.Load(); n != int64(len(badLines)) {
+			t.Errorf("stream %v: counted %d malformed records, want %d", stream, n, len(badLines))
+		}
+		if logged.String() != want {
+			t.Errorf("stream %v: logged %q, want %q", stream, logged.String(), want)
+		}
+	}
+}
+
+func TestValidateOneBadLine(t *testing.T) {
+	path := writeInput(t, "Hamburg;12.0\nBulawayo;8.9\nPalembang 38.8\nSt. John's;15.2\n")
+	stdout, stderr, code := runMain(t, "-validate", path)
+	if code == 0 {
+		t.Errorf("-validate exited with 0 for a malformed line")
+	}
+	if !strings.Contains(stderr, "found 1 malformed records") || !strings.Contains(stderr, "line 3: missing delimiter") {
+		t.Errorf("-validate logged %q, want line 3 reported", stderr)
+	}
+	if stdout != "" {
+		t.Errorf("-validate printed %q, want nothing", stdout)
+	}
+
+	path = writeInput(t, "Hamburg;12.0\nBulawayo;8.9\n")
+	if stdout, stderr, code := runMain(t, "-validate", path); code != 0 || stdout != "" || stderr != "" {
+		t.Errorf("-validate of a valid file: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+}
+
+// Lines of a -start window are numbered from the window, whose malformed
+// lines are located by their byte in the file too; a window from the first
+// byte numbers lines from the start of the file as usual.
+func TestStrictWindowLines(t *testing.T) {
+	path := writeInput(t, "a;1.0\nbad\nb;2.0\nworse\nc;3.0\n")
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-start", "6"}, []string{"line 1 of the window, at byte 6: missing delimiter", "line 3 of the window, at byte 16: missing delimiter"}},
+		{[]string{"-start", "7"}, []string{"line 2 of the window, at byte 16: missing delimiter"}},
+		{[]string{"-length", "12"}, []string{"line 2: missing delimiter"}},
+	} {
+		args := append(append([]string{"-strict"}, tc.args...), path)
+		_, stderr, code := runMain(t, args...)
+		if code != 0 || strings.Count(stderr, "  line ") != len(tc.want) {
+			t.Errorf("%v: exit %d, stderr %q, want %d lines reported", tc.args, code, stderr, len(tc.want))
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(stderr, want) {
+				t.Errorf("%v: stderr %q, want %q in it", tc.args, stderr, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package onebrc
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// printSummary appends the coldest and hottest reading to text output, see
+// -global-summary.
+var printSummary = false
+
+// writeSummary writes the lowest minimum and highest maximum among the
+// stations in names with the stations that recorded them. Ties go to the
+// station printed first.
+func writeSummary(writer *bufio.Writer, names []string, stationData map[string]*StationData) {
+	if len(names) == 0 {
+		return
+	}
+	coldest, hottest := names[0], names[0]
+	for _, name := range names[1:] {
+		s := stationData[name]
+		if s.MinTemp < stationData[coldest].MinTemp {
+			coldest = name
+		}
+		if s.MaxTemp > stationData[hottest].MaxTemp {
+			hottest = name
+		}
+	}
+	fmt.Fprintf(writer, "coldest %s=%s, hottest %s=%s\n",
+		coldest, formatTemp(getFloatValue(stationData[coldest].MinTemp)),
+		hottest, formatTemp(getFloatValue(stationData[hottest].MaxTemp)))
+}
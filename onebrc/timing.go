@@ -0,0 +1,48 @@
+package onebrc
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// timerJSON is the -timer-format json report, one object per run.
+type timerJSON struct {
+	ElapsedNs  int64   `json:"elapsed_ns"`
+	Bytes      uint64  `json:"bytes"`
+	Rows       int64   `json:"rows"`
+	RowsPerSec float64 `json:"rows_per_sec"`
+	Workers    int     `json:"workers"`
+}
+
+// runStats is what a run read and how, for the -timer reports. Both
+// formats report the same values.
+type runStats struct {
+	// input bytes read: parsed, after decompression and without what
+	// -start and -length cut off, or the result files of -combine
+	bytes uint64
+	// the most workers that read any input of the run: inputs with less
+	// than minWorkerBytes per worker get fewer than -workers
+	workers int
+}
+
+// lastRun is the runStats of the current or last run, reset by beginRun.
+var lastRun runStats
+
+// writeTimerJSON writes the run's timing and throughput to w as a single
+// line of JSON. bytes and workers are those of runStats, which the text
+// report shows too.
+func writeTimerJSON(w io.Writer, elapsed time.Duration, bytes uint64, rows int64, workers int) error {
+	b, err := json.Marshal(timerJSON{
+		ElapsedNs:  elapsed.Nanoseconds(),
+		Bytes:      bytes,
+		Rows:       rows,
+		RowsPerSec: float64(rows) / elapsed.Seconds(),
+		Workers:    workers,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,69 @@
+package onebrc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// The workers of -timer-format json are those that ran, after small mapped
+// inputs got fewer than -workers, not the flag value.
+func TestTimerJSONWorkers(t *testing.T) {
+	line := []byte("Hamburg;12.0\n")
+	data := bytes.Repeat(line, 5*minWorkerBytes/2/len(line))
+	tests := []struct {
+		name  string
+		input string
+		stdin bool
+		want  int
+	}{
+		{"tiny file", "Hamburg;12.0\nBulawayo;8.9\n", false, 1},
+		{"2.5 MiB file", string(data), false, 2},
+		{"stdin", string(data), true, 4},
+	}
+	for _, tt := range tests {
+		var stderr string
+		var code int
+		if tt.stdin {
+			_, stderr, code = runMainStdin(t, tt.input, "-workers", "4", "-timer-format", "json", "-")
+		} else {
+			_, stderr, code = runMain(t, "-workers", "4", "-timer-format", "json", writeInput(t, tt.input))
+		}
+		if code != 0 {
+			t.Fatalf("%s: exit %d, stderr %q", tt.name, code, stderr)
+		}
+		var report timerJSON
+		if err := json.Unmarshal([]byte(stderr), &report); err != nil {
+			t.Fatalf("%s: stderr %q isn't the JSON report: %v", tt.name, stderr, err)
+		}
+		if report.Workers != tt.want || report.Bytes != uint64(len(tt.input)) {
+			t.Errorf("%s: %d workers for %d bytes, want %d for %d", tt.name, report.Workers, report.Bytes, tt.want, len(tt.input))
+		}
+	}
+}
+
+// -combine reads result files, not measurements, and both reports count
+// them; the text report names the same workers as the JSON one.
+func TestTimerReportsAgree(t *testing.T) {
+	part := "{Bulawayo=8.9/8.9/8.9/1, Hamburg=-3.4/4.3/12.0/2}\n"
+	path := writeInput(t, part)
+	_, stderr, code := runMain(t, "-combine", "-timer-format", "json", path, path)
+	if code != 0 {
+		t.Fatalf("-combine: exit %d, stderr %q", code, stderr)
+	}
+	var report timerJSON
+	if err := json.Unmarshal([]byte(stderr), &report); err != nil {
+		t.Fatalf("-combine: stderr %q isn't the JSON report: %v", stderr, err)
+	}
+	if report.Bytes != uint64(2*len(part)) || report.Rows != 6 || report.Workers != 1 {
+		t.Errorf("-combine: %d bytes, %d rows, %d workers; want %d, 6 and 1", report.Bytes, report.Rows, report.Workers, 2*len(part))
+	}
+
+	line := []byte("Hamburg;12.0\n")
+	data := bytes.Repeat(line, 5*minWorkerBytes/2/len(line))
+	_, stderr, _ = runMain(t, "-workers", "4", "-timer", writeInput(t, string(data)))
+	if want := "workers=2"; !strings.Contains(stderr, want) {
+		t.Errorf("-timer logged %q, want %q", stderr, want)
+	}
+}
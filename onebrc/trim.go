@@ -0,0 +1,23 @@
+package onebrc
+
+// trimNames strips spaces and tabs around station names, so "Foo " and
+// "Foo" are one station. The fold hash covers the untrimmed bytes, so
+// trimming rehashes every name; see -trim-names.
+var trimNames = false
+
+// trimName returns the address and length of the name at nameAddress
+// without leading and trailing spaces and tabs.
+func trimName(scanner *Scanner, nameAddress uint64, nameLength int) (uint64, int) {
+	end := nameAddress + uint64(nameLength)
+	for nameAddress < end && isBlank(scanner.getByteAt(nameAddress)) {
+		nameAddress++
+	}
+	for end > nameAddress && isBlank(scanner.getByteAt(end-1)) {
+		end--
+	}
+	return nameAddress, int(end - nameAddress)
+}
+
+func isBlank(b byte) bool {
+	return b == ' ' || b == '\t'
+}
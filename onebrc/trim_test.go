@@ -0,0 +1,31 @@
+package onebrc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbukhari/1brc/internal/fasthash"
+)
+
+func TestTrimNames(t *testing.T) {
+	long := strings.Repeat("Bulawayo", 3)
+	input := " Foo ;1.0\nFoo;3.0\n\tFoo\t;5.0\nBar  ;2.0\n" + long + ";1.0\n" + long + "    ;2.0\n"
+	want := "{Bar=2.0/2.0/2.0, " + long + "=1.0/1.5/2.0, Foo=1.0/3.0/5.0}\n"
+
+	if got := aggregate(t, input, 1); strings.Count(got, "=") != 6 {
+		t.Errorf("without -trim-names: got %q, want 6 stations", got)
+	}
+	// as Main sets them for -trim-names
+	setFlag(t, &trimNames, true)
+	setFlag(t, &nameHash, fasthash.HashBytes64)
+	for _, workers := range []int{1, 4} {
+		if got := aggregate(t, input, workers); got != want {
+			t.Errorf("%d workers: got %q, want %q", workers, got, want)
+		}
+	}
+
+	path := writeInput(t, input)
+	if stdout, stderr, code := runMain(t, "-trim-names", path); stdout != want || code != 0 {
+		t.Errorf("-trim-names: exit %d, got %q, want %q, stderr %q", code, stdout, want, stderr)
+	}
+}
@@ -0,0 +1,47 @@
+package onebrc
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// version and commit can be stamped at build time with -ldflags
+// "-X github.com/nbukhari/1brc/onebrc.version=v1.2.3
+// -X github.com/nbukhari/1brc/onebrc.commit=abc123"; otherwise they fall
+// back to the module and VCS info embedded by the go tool.
+var (
+	version = ""
+	commit  = ""
+)
+
+// printVersion writes the module version, Go version and git commit of the
+// running binary.
+func printVersion(w io.Writer) {
+	v, c, modified := version, commit, false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if commit == "" {
+					c = setting.Value
+				}
+			case "vcs.modified":
+				modified = commit == "" && setting.Value == "true"
+			}
+		}
+	}
+	if v == "" {
+		v = "(devel)"
+	}
+	if c == "" {
+		c = "unknown"
+	} else if modified {
+		c += "-dirty"
+	}
+	fmt.Fprintf(w, "onebrc %s\ngo %s\ncommit %s\n", v, runtime.Version(), c)
+}
@@ -0,0 +1,37 @@
+//go:build js && wasm
+
+package onebrc
+
+import (
+	"strings"
+	"syscall/js"
+)
+
+// inBrowser makes Main export aggregate1brc to JavaScript instead of
+// running the command line.
+const inBrowser = true
+
+// exportJS registers aggregate1brc(bytes), which aggregates the
+// measurements in a Uint8Array and returns the text output, then blocks so
+// the function stays callable. A browser gives one thread, so a single
+// worker parses. Build with GOOS=js GOARCH=wasm and load the module with
+// the wasm_exec.js of the same Go release.
+func exportJS() {
+	js.Global().Set("aggregate1brc", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) != 1 || args[0].Type() != js.TypeObject {
+			return js.Global().Get("Error").New("aggregate1brc takes one Uint8Array")
+		}
+		data := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(data, args[0])
+		if err := checkBinary(data); err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+
+		var out strings.Builder
+		if err := printResults(&out, AggregateBytes(data, 1)); err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return out.String()
+	}))
+	select {}
+}
@@ -0,0 +1,8 @@
+//go:build !(js && wasm)
+
+package onebrc
+
+const inBrowser = false
+
+// exportJS only exists for the js/wasm build, see wasm_js.go.
+func exportJS() {}
@@ -0,0 +1,58 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Byte window of every mapped input to aggregate, see -start and -length.
+// A record belongs to the window its first byte falls in, so consecutive
+// windows of one file split its records exactly.
+var (
+	windowStart  int64 = 0
+	windowLength int64 = 0 // 0 for up to the end of the file
+)
+
+// windowBounds returns the window of a size-byte file, in bytes.
+func windowBounds(size int64) (int64, int64) {
+	start := min(windowStart, size)
+	if windowLength == 0 {
+		return start, size
+	}
+	return start, min(start+windowLength, size)
+}
+
+// windowRecords trims data, which maps the file from byte base, to the
+// records starting in [start, end). The byte before start must be mapped
+// so a record starting at start is recognized; eof tells whether data runs
+// up to the end of the file. It fails if the record crossing end doesn't
+// finish within data.
+func windowRecords(data []byte, base, start, end int64, eof bool) ([]byte, error) {
+	// data can end before the window, see the block devices of createWorkers
+	from, to := start-base, min(end-base, int64(len(data)))
+	if from > int64(len(data)) {
+		return nil, nil
+	}
+	if start > 0 {
+		i := bytes.IndexByte(data[from-1:], '\n')
+		if i < 0 {
+			return nil, nil
+		}
+		from += int64(i)
+	}
+	if from >= to {
+		return nil, nil
+	}
+	if to < int64(len(data)) {
+		i := bytes.IndexByte(data[to-1:], '\n')
+		switch {
+		case i >= 0:
+			to += int64(i)
+		case eof:
+			to = int64(len(data))
+		default:
+			return nil, fmt.Errorf("record crossing byte %d is longer than -overlap", end)
+		}
+	}
+	return data[from:to], nil
+}
@@ -0,0 +1,58 @@
+package onebrc
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// A -start/-length run gives the output of a plain run over the records
+// starting in its window, cut out and written to their own file.
+func TestWindowMatchesExtractedRange(t *testing.T) {
+	data := sample(t, 20_000)
+	path := writeInput(t, string(data))
+	size := int64(len(data))
+
+	// the records starting in [start, end), found without windowRecords
+	extract := func(start, end int64) []byte {
+		var out []byte
+		for pos := int64(0); pos < size; {
+			next := pos + int64(bytes.IndexByte(data[pos:], '\n')) + 1
+			if pos >= start && pos < end {
+				out = append(out, data[pos:next]...)
+			}
+			pos = next
+		}
+		return out
+	}
+
+	windows := [][2]int64{
+		{0, 1000},
+		{1, 1000},            // starts inside the first record
+		{size / 3, size / 3}, // bounds that likely fall mid-record
+		{size / 2, 0},        // up to the end of the file
+		{size - 3, 100},      // only the tail of the last record
+		{size + 10, 0},       // past the end
+	}
+	for _, w := range windows {
+		t.Run(fmt.Sprintf("start=%d,length=%d", w[0], w[1]), func(t *testing.T) {
+			end := size
+			if w[1] > 0 {
+				end = min(w[0]+w[1], size)
+			}
+			want, stderr, code := runMain(t, "-workers", "2", writeInput(t, string(extract(w[0], end))))
+			if code != 0 {
+				t.Fatalf("extracted range: exit %d, stderr %q", code, stderr)
+			}
+			got, stderr, code := runMain(t, "-workers", "2",
+				"-start", strconv.FormatInt(w[0], 10), "-length", strconv.FormatInt(w[1], 10), path)
+			if code != 0 {
+				t.Fatalf("window: exit %d, stderr %q", code, stderr)
+			}
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
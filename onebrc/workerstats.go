@@ -0,0 +1,50 @@
+package onebrc
+
+import (
+	"bytes"
+	"log"
+	"time"
+)
+
+// perWorkerStats makes the mapped-file workers log what each of them did,
+// see -per-worker-stats. A worker that took far more rows or time than the
+// rest shows load imbalance, e.g. one of them parsing a denser region.
+var perWorkerStats = false
+
+// workerStat is what one worker did for one input.
+type workerStat struct {
+	chunks int
+	rows   int64
+	wall   time.Duration
+}
+
+// countRows sums the measurements recorded in a worker map.
+func countRows(results *stationMap) int64 {
+	var rows int64
+	results.Range(func(_ uint64, s *StationData) bool {
+		rows += s.Count
+		return true
+	})
+	return rows
+}
+
+// countLines counts the lines of a chunk of a shared-map worker, which has
+// no map of its own to sum.
+func countLines(chunk []byte) int64 {
+	return int64(bytes.Count(chunk, []byte{'\n'}))
+}
+
+// reportWorkerStats logs one line per worker and the spread of their rows.
+func reportWorkerStats(stats []workerStat) {
+	var most, least int64 = 0, -1
+	for i, s := range stats {
+		log.Printf("worker %d: %d chunks, %d rows, %v", i, s.chunks, s.rows, s.wall.Round(time.Microsecond))
+		most = max(most, s.rows)
+		if least < 0 || s.rows < least {
+			least = s.rows
+		}
+	}
+	if len(stats) > 1 && least > 0 {
+		log.Printf("workers: most rows %.2fx the fewest", float64(most)/float64(least))
+	}
+}